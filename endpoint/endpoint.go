@@ -0,0 +1,104 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TTL is a structure defining the TTL of a DNS record
+type TTL int64
+
+// IsConfigured returns true if TTL has a meaningful value, false otherwise
+func (ttl TTL) IsConfigured() bool {
+	return ttl > 0
+}
+
+// Supported record types
+const (
+	RecordTypeA     = "A"
+	RecordTypeCNAME = "CNAME"
+	RecordTypeTXT   = "TXT"
+)
+
+// acmeChallengeLabel is the Labels key used to mark an endpoint as an ACME DNS-01 challenge
+// record, following the `Labels["acme-challenge"]` convention: such records are short-TTL,
+// not owned by any single reconcile, and additive-only, since cert-manager / lego-style
+// clients may keep more than one challenge value live under the same name while rotating.
+const acmeChallengeLabel = "acme-challenge"
+
+// acmeChallengePrefix is the well-known DNS-01 challenge label, e.g. `_acme-challenge.foo.example.com`.
+const acmeChallengePrefix = "_acme-challenge."
+
+// ChallengeTTL is the default TTL used for ACME DNS-01 challenge records, short enough that
+// a CA's validation check isn't stuck looking at a stale value for long.
+const ChallengeTTL TTL = 60
+
+// IsACMEChallengeName reports whether a DNS name follows the `_acme-challenge.<host>` DNS-01
+// convention.
+func IsACMEChallengeName(dnsName string) bool {
+	return strings.HasPrefix(dnsName, acmeChallengePrefix)
+}
+
+// Endpoint is a high-level abstraction of a DNS record that can be applied to any
+// backing DNS provider.
+type Endpoint struct {
+	// DNSName is the hostname of the DNS record
+	DNSName string
+	// Target is the value of the DNS record
+	Target string
+	// RecordType is the type of the DNS record, e.g. A, CNAME, TXT
+	RecordType string
+	// Labels holds additional properties of the endpoint that are not part of the DNS record itself
+	Labels map[string]string
+	// RecordTTL is the TTL of the record, zero means "use the provider default"
+	RecordTTL TTL
+}
+
+// NewEndpoint initialization method to be used while creating an endpoint without a TTL
+func NewEndpoint(dnsName, target, recordType string) *Endpoint {
+	return NewEndpointWithTTL(dnsName, target, recordType, TTL(0))
+}
+
+// NewEndpointWithTTL initialization method to be used while creating an endpoint with a TTL
+func NewEndpointWithTTL(dnsName, target, recordType string, ttl TTL) *Endpoint {
+	return &Endpoint{
+		DNSName:    strings.TrimSuffix(dnsName, "."),
+		Target:     strings.TrimSuffix(target, "."),
+		RecordType: recordType,
+		Labels:     map[string]string{},
+		RecordTTL:  ttl,
+	}
+}
+
+// WithACMEChallenge marks the endpoint as an ACME DNS-01 challenge record and returns it for
+// chaining.
+func (e *Endpoint) WithACMEChallenge() *Endpoint {
+	e.Labels[acmeChallengeLabel] = "true"
+	return e
+}
+
+// IsACMEChallenge reports whether the endpoint is an ACME DNS-01 challenge record, per the
+// `Labels["acme-challenge"]` convention.
+func (e *Endpoint) IsACMEChallenge() bool {
+	return e.Labels[acmeChallengeLabel] == "true"
+}
+
+func (e *Endpoint) String() string {
+	return fmt.Sprintf("%s %d IN %s %s", e.DNSName, e.RecordTTL, e.RecordType, e.Target)
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutils holds helpers shared between the various provider test suites.
+package testutils
+
+import (
+	"github.com/moonwalker/external-dns/endpoint"
+)
+
+// SameEndpoints compares two slices of endpoints regardless of order.
+func SameEndpoints(a, b []*endpoint.Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := map[string]int{}
+	for _, e := range a {
+		counts[e.String()]++
+	}
+	for _, e := range b {
+		counts[e.String()]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"github.com/moonwalker/external-dns/endpoint"
+)
+
+// Capabilities describes what a provider is able to express, so the reconciler can skip or
+// downgrade changes a provider would otherwise reject outright.
+type Capabilities struct {
+	// SupportedRecordTypes lists the record types a provider can manage. An empty list means
+	// "no restriction", since most providers support at least A/CNAME/TXT.
+	SupportedRecordTypes []string
+	// SupportsTTL indicates whether a provider honors a per-record TTL.
+	SupportsTTL bool
+	// SupportsAlias indicates whether a provider supports alias/ANAME-style records.
+	SupportsAlias bool
+	// SupportsDryRun indicates whether a provider can no-op ApplyChanges without erroring.
+	SupportsDryRun bool
+	// SupportsZoneIDFilter indicates whether a provider can filter zones by id rather than name.
+	SupportsZoneIDFilter bool
+}
+
+// SupportsRecordType reports whether the given record type is usable with these capabilities.
+func (c Capabilities) SupportsRecordType(recordType string) bool {
+	if len(c.SupportedRecordTypes) == 0 {
+		return true
+	}
+	for _, supported := range c.SupportedRecordTypes {
+		if supported == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns a copy of changes with any endpoint whose record type the given capabilities
+// don't declare support for removed, so a provider is never handed a change it can't express.
+func Filter(changes *Changes, capabilities Capabilities) *Changes {
+	return &Changes{
+		Create:    filterEndpoints(changes.Create, capabilities),
+		UpdateOld: filterEndpoints(changes.UpdateOld, capabilities),
+		UpdateNew: filterEndpoints(changes.UpdateNew, capabilities),
+		Delete:    filterEndpoints(changes.Delete, capabilities),
+	}
+}
+
+func filterEndpoints(endpoints []*endpoint.Endpoint, capabilities Capabilities) []*endpoint.Endpoint {
+	var filtered []*endpoint.Endpoint
+	for _, ep := range endpoints {
+		if capabilities.SupportsRecordType(ep.RecordType) {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan computes the set of changes required to reconcile the desired
+// endpoints against what a provider currently has on record.
+package plan
+
+import (
+	"github.com/moonwalker/external-dns/endpoint"
+)
+
+// Changes holds the endpoints that need to be created, updated or deleted for a
+// provider to converge on the desired state.
+type Changes struct {
+	// Create contains endpoints that need to be created
+	Create []*endpoint.Endpoint
+	// UpdateOld contains the old version of endpoints to be updated
+	UpdateOld []*endpoint.Endpoint
+	// UpdateNew contains the new version of endpoints to be updated
+	UpdateNew []*endpoint.Endpoint
+	// Delete contains endpoints that need to be deleted
+	Delete []*endpoint.Endpoint
+}
+
+// IsAdditiveOnly reports whether an endpoint must only ever be merged into, never used to
+// replace, whatever else already exists under the same name (e.g. an ACME DNS-01 challenge
+// TXT record, where several concurrent values can be mid-rotation at once).
+func IsAdditiveOnly(ep *endpoint.Endpoint) bool {
+	return ep.IsACMEChallenge()
+}
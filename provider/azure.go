@@ -0,0 +1,1262 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
+	"github.com/Azure/azure-sdk-for-go/services/privatedns/mgmt/2018-09-01/privatedns"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/moonwalker/external-dns/endpoint"
+	"github.com/moonwalker/external-dns/plan"
+	"github.com/moonwalker/external-dns/provider/verify"
+)
+
+// recordTTL is used as a default when an endpoint doesn't carry its own TTL
+const recordTTL = 300
+
+// maxConcurrentZoneUpdates bounds how many zones are reconciled at once, so a subscription with
+// many zones doesn't open an unbounded number of concurrent ARM connections.
+const maxConcurrentZoneUpdates = 10
+
+// maxThrottleRetries bounds how many times a single ARM call is retried after a 429 response
+// before giving up and surfacing the error.
+const maxThrottleRetries = 5
+
+// defaultThrottleBackoff is used when ARM returns a 429 without a usable Retry-After header.
+const defaultThrottleBackoff = 5 * time.Second
+
+// recordSetKey identifies a single Azure record set: one relative name and type within one zone.
+type recordSetKey struct {
+	zone         string
+	relativeName string
+	recordType   string
+}
+
+// retryOn429 retries fn whenever it fails with a 429 (Too Many Requests) response from ARM,
+// sleeping for the duration named in the response's Retry-After header (or defaultThrottleBackoff
+// when none is present) between attempts.
+func retryOn429(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		wait, throttled := throttleWait(err)
+		if !throttled || attempt == maxThrottleRetries {
+			return err
+		}
+		log.Debugf("Azure ARM request throttled, retrying in %s", wait)
+		time.Sleep(wait)
+	}
+}
+
+// throttleWait reports whether err represents a 429 response from ARM, and if so how long to
+// wait before retrying.
+func throttleWait(err error) (time.Duration, bool) {
+	detailed, ok := err.(autorest.DetailedError)
+	if !ok || detailed.Response == nil || detailed.Response.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if retryAfter := detailed.Response.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, convErr := strconv.Atoi(retryAfter); convErr == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return defaultThrottleBackoff, true
+}
+
+// azureDNSTypes are the record types exposed by the Azure DNS (and Azure Private DNS) APIs that
+// external-dns knows how to reconcile. Anything else (NS, SOA, ...) is left untouched.
+var azureDNSTypes = map[string]bool{
+	endpoint.RecordTypeA:     true,
+	endpoint.RecordTypeCNAME: true,
+	endpoint.RecordTypeTXT:   true,
+}
+
+// azureCapabilities is shared by both the public and private Azure DNS providers: the two
+// control planes expose the same record types and honor TTL, dry-run and zone id filtering
+// identically.
+var azureCapabilities = plan.Capabilities{
+	SupportedRecordTypes: []string{endpoint.RecordTypeA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT},
+	SupportsTTL:          true,
+	SupportsDryRun:       true,
+	SupportsZoneIDFilter: true,
+}
+
+// ZonesClient is an interface over the Azure DNS zones client, for mocking purposes.
+type ZonesClient interface {
+	ListByResourceGroup(resourceGroupName string, top *int32) (dns.ZoneListResult, error)
+	ListByResourceGroupNextResults(lastResults dns.ZoneListResult) (dns.ZoneListResult, error)
+}
+
+// RecordsClient is an interface over the Azure DNS records client, for mocking purposes.
+type RecordsClient interface {
+	ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (dns.RecordSetListResult, error)
+	ListByDNSZoneNextResults(list dns.RecordSetListResult) (dns.RecordSetListResult, error)
+	Get(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType) (dns.RecordSet, error)
+	Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (autorest.Response, error)
+	CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (dns.RecordSet, error)
+}
+
+// PrivateZonesClient is an interface over the Azure Private DNS zones client, for mocking purposes.
+type PrivateZonesClient interface {
+	ListByResourceGroup(resourceGroupName string, top *int32) (privatedns.PrivateZoneListResult, error)
+	ListByResourceGroupNextResults(lastResults privatedns.PrivateZoneListResult) (privatedns.PrivateZoneListResult, error)
+	ListVirtualNetworkLinks(resourceGroupName string, privateZoneName string, top *int32) (privatedns.VirtualNetworkLinkListResult, error)
+}
+
+// PrivateRecordsClient is an interface over the Azure Private DNS record sets client, for mocking purposes.
+type PrivateRecordsClient interface {
+	ListByDNSZone(resourceGroupName string, privateZoneName string, top *int32) (privatedns.RecordSetListResult, error)
+	ListByDNSZoneNextResults(list privatedns.RecordSetListResult) (privatedns.RecordSetListResult, error)
+	Get(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType) (privatedns.RecordSet, error)
+	Delete(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType, ifMatch string) (autorest.Response, error)
+	CreateOrUpdate(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType, parameters privatedns.RecordSet, ifMatch string, ifNoneMatch string) (privatedns.RecordSet, error)
+}
+
+func init() {
+	RegisterProvider("azure", azureProviderFactory(false), azureCapabilities)
+	RegisterProvider("azure-private-dns", azureProviderFactory(true), azureCapabilities)
+}
+
+// azureProviderFactory returns a Factory that builds a public or private Azure DNS provider
+// from a flat config bag, as handed out by the --provider-config flag.
+func azureProviderFactory(privateDNS bool) Factory {
+	return func(config map[string]string) (Provider, error) {
+		domainFilter := NewDomainFilter(splitConfigList(config["domainFilter"]))
+		zoneIDFilter := NewZoneIDFilter(splitConfigList(config["zoneIDFilter"]))
+		dryRun := config["dryRun"] == "true"
+		verifyDoHURLs := splitConfigList(config["verifyDoh"])
+		return NewAzureProvider(config["configFile"], domainFilter, zoneIDFilter, config["resourceGroup"], dryRun, privateDNS, verifyDoHURLs)
+	}
+}
+
+func splitConfigList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// azureConfig describes the minimal credentials needed to talk to the Azure DNS control plane.
+// It is read from the `--azure-config-file` JSON document, in the same shape as the Azure
+// provider plugins used by cloud-provider-azure.
+type azureConfig struct {
+	Cloud           string `json:"cloud"`
+	SubscriptionID  string `json:"subscriptionId"`
+	ResourceGroup   string `json:"resourceGroup"`
+	TenantID        string `json:"tenantId"`
+	AADClientID     string `json:"aadClientId"`
+	AADClientSecret string `json:"aadClientSecret"`
+}
+
+// azureZone is a provider-neutral view of a DNS zone, common to both the public and private
+// Azure DNS control planes.
+type azureZone struct {
+	name string
+	id   string
+}
+
+// azureBackend is the seam between azureReconciler, below, and a concrete Azure DNS control
+// plane (public or private). Each method talks to exactly one ARM API generation; everything
+// else - domain/zone-id filtering, record-set grouping, bounded zone concurrency, 429 retry and
+// ACME challenge value merging - is implemented once, against this interface, so the public and
+// private providers can never drift out of step with each other the way they used to.
+type azureBackend interface {
+	// rawZones returns every zone in the configured resource group, unfiltered and unpaged (the
+	// implementation follows NextLink internally).
+	rawZones() ([]azureZone, error)
+	// zoneUsable reports whether a zone that already passed domain/zone-id filtering should be
+	// considered at all. Always true for public zones; for private zones it's false unless the
+	// zone is linked to at least one virtual network.
+	zoneUsable(zoneName string) bool
+	// listRecords returns one endpoint per target of every record set external-dns manages in
+	// zoneName, following paging internally.
+	listRecords(zoneName string) ([]*endpoint.Endpoint, error)
+	// getTXTValues returns the current values and TTL of a TXT record set, or ok=false if it
+	// doesn't exist (or couldn't be read, which is treated the same as not existing).
+	getTXTValues(zoneName, relativeName string) (values []string, ttl int64, ok bool)
+	// upsert pushes a record set carrying every given target as a single ARM call.
+	upsert(zoneName, relativeName, recordType string, targets []string, ttl int64) error
+	// delete removes a record set outright.
+	delete(zoneName, relativeName, recordType string) error
+}
+
+// azureReconciler implements the shared reconciliation loop - Zones/Records/ApplyChanges and
+// everything they call - against an azureBackend. AzureProvider and AzurePrivateDNSProvider are
+// both thin wrappers around one, each constructed with a backend for their respective control
+// plane.
+type azureReconciler struct {
+	domainFilter DomainFilter
+	zoneIDFilter ZoneIDFilter
+	dryRun       bool
+	backend      azureBackend
+	verifier     verify.Verifier
+}
+
+// AzureProvider implements the DNS provider for Microsoft's Azure DNS service
+type AzureProvider struct {
+	*azureReconciler
+}
+
+// AzurePrivateDNSProvider implements the DNS provider for Microsoft's Azure Private DNS service.
+// It behaves identically to AzureProvider against the `privatedns` control plane instead of the
+// public `dns` one, and additionally only considers zones that are linked to at least one
+// virtual network, since an unlinked private zone can't resolve for anybody.
+type AzurePrivateDNSProvider struct {
+	*azureReconciler
+}
+
+// NewAzureProvider creates a new AzureProvider (or AzurePrivateDNSProvider, when privateDNS is
+// true) configured via the given credentials. Both satisfy the Provider interface so callers
+// can treat them interchangeably. When verifyDoHURLs is non-empty, every ApplyChanges call is
+// followed by polling those DNS-over-HTTPS resolvers until the change is observed to have
+// propagated, logging a warning if it never does.
+func NewAzureProvider(configFile string, domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, resourceGroup string, dryRun bool, privateDNS bool, verifyDoHURLs []string) (Provider, error) {
+	cfg, err := getAzureConfig(configFile, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getAzureToken(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifier verify.Verifier
+	if len(verifyDoHURLs) > 0 {
+		verifier = verify.NewDoHVerifier(verifyDoHURLs...)
+	}
+
+	if privateDNS {
+		zonesClient := privatedns.NewPrivateZonesClient(cfg.SubscriptionID)
+		zonesClient.Authorizer = token
+		vnetLinksClient := privatedns.NewVirtualNetworkLinksClient(cfg.SubscriptionID)
+		vnetLinksClient.Authorizer = token
+		recordSetsClient := privatedns.NewRecordSetsClient(cfg.SubscriptionID)
+		recordSetsClient.Authorizer = token
+
+		return &AzurePrivateDNSProvider{
+			azureReconciler: &azureReconciler{
+				domainFilter: domainFilter,
+				zoneIDFilter: zoneIDFilter,
+				dryRun:       dryRun,
+				backend: &azurePrivateBackend{
+					resourceGroup: cfg.ResourceGroup,
+					zonesClient:   &azurePrivateZonesClientAdapter{client: zonesClient, vnetLinks: vnetLinksClient},
+					recordsClient: &azurePrivateRecordsClientAdapter{client: recordSetsClient},
+				},
+				verifier: verifier,
+			},
+		}, nil
+	}
+
+	zonesClient := dns.NewZonesClient(cfg.SubscriptionID)
+	zonesClient.Authorizer = token
+	recordsClient := dns.NewRecordSetsClient(cfg.SubscriptionID)
+	recordsClient.Authorizer = token
+
+	return &AzureProvider{
+		azureReconciler: &azureReconciler{
+			domainFilter: domainFilter,
+			zoneIDFilter: zoneIDFilter,
+			dryRun:       dryRun,
+			backend: &azurePublicBackend{
+				resourceGroup: cfg.ResourceGroup,
+				zonesClient:   &azureZonesClientAdapter{client: zonesClient},
+				recordsClient: &azureRecordsClientAdapter{client: recordsClient},
+			},
+			verifier: verifier,
+		},
+	}, nil
+}
+
+func getAzureConfig(configFile, resourceGroupOverride string) (*azureConfig, error) {
+	contents, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Azure config file '%s': %v", configFile, err)
+	}
+	cfg := &azureConfig{}
+	if err := json.Unmarshal(contents, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse Azure config file '%s': %v", configFile, err)
+	}
+	if resourceGroupOverride != "" {
+		cfg.ResourceGroup = resourceGroupOverride
+	}
+	return cfg, nil
+}
+
+func getAzureToken(cfg *azureConfig) (autorest.Authorizer, error) {
+	env, err := azure.EnvironmentFromName(cfg.Cloud)
+	if err != nil {
+		env = azure.PublicCloud
+	}
+	credentials := auth.NewClientCredentialsConfig(cfg.AADClientID, cfg.AADClientSecret, cfg.TenantID)
+	credentials.AADEndpoint = env.ActiveDirectoryEndpoint
+	credentials.Resource = env.ResourceManagerEndpoint
+	return credentials.Authorizer()
+}
+
+// The four adapters below sit between ZonesClient/RecordsClient/PrivateZonesClient/
+// PrivateRecordsClient and the real generated SDK clients, which - unlike the interfaces above -
+// take a context.Context as their first argument and return a ...Page type that must be walked
+// with NextWithContext. Pinning context.Background() and draining every page up front here keeps
+// that SDK generation detail out of azurePublicBackend/azurePrivateBackend and out of the mocks in
+// azure_test.go, which are written against the simpler, pre-paged shape.
+
+// azureZonesClientAdapter adapts a real dns.ZonesClient onto ZonesClient.
+type azureZonesClientAdapter struct {
+	client dns.ZonesClient
+}
+
+func (a *azureZonesClientAdapter) ListByResourceGroup(resourceGroupName string, top *int32) (dns.ZoneListResult, error) {
+	ctx := context.Background()
+	page, err := a.client.ListByResourceGroup(ctx, resourceGroupName, top)
+	if err != nil {
+		return dns.ZoneListResult{}, err
+	}
+	var zones []dns.Zone
+	for page.NotDone() {
+		zones = append(zones, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return dns.ZoneListResult{}, err
+		}
+	}
+	return dns.ZoneListResult{Value: &zones}, nil
+}
+
+// ListByResourceGroupNextResults is never actually called: ListByResourceGroup above already
+// drains every page, so the ZoneListResult it returns always has a nil NextLink.
+func (a *azureZonesClientAdapter) ListByResourceGroupNextResults(lastResults dns.ZoneListResult) (dns.ZoneListResult, error) {
+	return dns.ZoneListResult{}, nil
+}
+
+// azureRecordsClientAdapter adapts a real dns.RecordSetsClient onto RecordsClient.
+type azureRecordsClientAdapter struct {
+	client dns.RecordSetsClient
+}
+
+func (a *azureRecordsClientAdapter) ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (dns.RecordSetListResult, error) {
+	ctx := context.Background()
+	page, err := a.client.ListByDNSZone(ctx, resourceGroupName, zoneName, top, "")
+	if err != nil {
+		return dns.RecordSetListResult{}, err
+	}
+	var recordSets []dns.RecordSet
+	for page.NotDone() {
+		recordSets = append(recordSets, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return dns.RecordSetListResult{}, err
+		}
+	}
+	return dns.RecordSetListResult{Value: &recordSets}, nil
+}
+
+// ListByDNSZoneNextResults is never actually called, for the same reason as
+// azureZonesClientAdapter.ListByResourceGroupNextResults above.
+func (a *azureRecordsClientAdapter) ListByDNSZoneNextResults(list dns.RecordSetListResult) (dns.RecordSetListResult, error) {
+	return dns.RecordSetListResult{}, nil
+}
+
+func (a *azureRecordsClientAdapter) Get(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType) (dns.RecordSet, error) {
+	return a.client.Get(context.Background(), resourceGroupName, zoneName, relativeRecordSetName, recordType)
+}
+
+func (a *azureRecordsClientAdapter) Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (autorest.Response, error) {
+	return a.client.Delete(context.Background(), resourceGroupName, zoneName, relativeRecordSetName, recordType, ifMatch)
+}
+
+func (a *azureRecordsClientAdapter) CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (dns.RecordSet, error) {
+	return a.client.CreateOrUpdate(context.Background(), resourceGroupName, zoneName, relativeRecordSetName, recordType, parameters, ifMatch, ifNoneMatch)
+}
+
+// azurePrivateZonesClientAdapter adapts a real privatedns.PrivateZonesClient onto
+// PrivateZonesClient. Virtual network links live on their own privatedns.VirtualNetworkLinksClient
+// in the real SDK, so the adapter needs one of those too even though our interface bundles both
+// concerns onto a single PrivateZonesClient.
+type azurePrivateZonesClientAdapter struct {
+	client    privatedns.PrivateZonesClient
+	vnetLinks privatedns.VirtualNetworkLinksClient
+}
+
+func (a *azurePrivateZonesClientAdapter) ListByResourceGroup(resourceGroupName string, top *int32) (privatedns.PrivateZoneListResult, error) {
+	ctx := context.Background()
+	page, err := a.client.ListByResourceGroup(ctx, resourceGroupName, top)
+	if err != nil {
+		return privatedns.PrivateZoneListResult{}, err
+	}
+	var zones []privatedns.PrivateZone
+	for page.NotDone() {
+		zones = append(zones, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return privatedns.PrivateZoneListResult{}, err
+		}
+	}
+	return privatedns.PrivateZoneListResult{Value: &zones}, nil
+}
+
+// ListByResourceGroupNextResults is never actually called, for the same reason as
+// azureZonesClientAdapter.ListByResourceGroupNextResults above.
+func (a *azurePrivateZonesClientAdapter) ListByResourceGroupNextResults(lastResults privatedns.PrivateZoneListResult) (privatedns.PrivateZoneListResult, error) {
+	return privatedns.PrivateZoneListResult{}, nil
+}
+
+func (a *azurePrivateZonesClientAdapter) ListVirtualNetworkLinks(resourceGroupName string, privateZoneName string, top *int32) (privatedns.VirtualNetworkLinkListResult, error) {
+	ctx := context.Background()
+	page, err := a.vnetLinks.List(ctx, resourceGroupName, privateZoneName, top)
+	if err != nil {
+		return privatedns.VirtualNetworkLinkListResult{}, err
+	}
+	var links []privatedns.VirtualNetworkLink
+	for page.NotDone() {
+		links = append(links, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return privatedns.VirtualNetworkLinkListResult{}, err
+		}
+	}
+	return privatedns.VirtualNetworkLinkListResult{Value: &links}, nil
+}
+
+// azurePrivateRecordsClientAdapter adapts a real privatedns.RecordSetsClient onto
+// PrivateRecordsClient. The real client's List/Get/Delete/CreateOrUpdate methods are named and
+// ordered slightly differently than their public dns.RecordSetsClient counterparts (List instead
+// of ListByDNSZone, recordType before relativeRecordSetName), which this adapter papers over.
+type azurePrivateRecordsClientAdapter struct {
+	client privatedns.RecordSetsClient
+}
+
+func (a *azurePrivateRecordsClientAdapter) ListByDNSZone(resourceGroupName string, privateZoneName string, top *int32) (privatedns.RecordSetListResult, error) {
+	ctx := context.Background()
+	page, err := a.client.List(ctx, resourceGroupName, privateZoneName, top, "")
+	if err != nil {
+		return privatedns.RecordSetListResult{}, err
+	}
+	var recordSets []privatedns.RecordSet
+	for page.NotDone() {
+		recordSets = append(recordSets, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return privatedns.RecordSetListResult{}, err
+		}
+	}
+	return privatedns.RecordSetListResult{Value: &recordSets}, nil
+}
+
+// ListByDNSZoneNextResults is never actually called, for the same reason as
+// azureZonesClientAdapter.ListByResourceGroupNextResults above.
+func (a *azurePrivateRecordsClientAdapter) ListByDNSZoneNextResults(list privatedns.RecordSetListResult) (privatedns.RecordSetListResult, error) {
+	return privatedns.RecordSetListResult{}, nil
+}
+
+func (a *azurePrivateRecordsClientAdapter) Get(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType) (privatedns.RecordSet, error) {
+	return a.client.Get(context.Background(), resourceGroupName, privateZoneName, recordType, relativeRecordSetName)
+}
+
+func (a *azurePrivateRecordsClientAdapter) Delete(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType, ifMatch string) (autorest.Response, error) {
+	return a.client.Delete(context.Background(), resourceGroupName, privateZoneName, recordType, relativeRecordSetName, ifMatch)
+}
+
+func (a *azurePrivateRecordsClientAdapter) CreateOrUpdate(resourceGroupName string, privateZoneName string, relativeRecordSetName string, recordType privatedns.RecordType, parameters privatedns.RecordSet, ifMatch string, ifNoneMatch string) (privatedns.RecordSet, error) {
+	return a.client.CreateOrUpdate(context.Background(), resourceGroupName, privateZoneName, recordType, relativeRecordSetName, parameters, ifMatch, ifNoneMatch)
+}
+
+// Zones returns the list of hosted zones that match the provider's domain and zone id filters
+// (and, for a private backend, are linked to at least one virtual network).
+func (r *azureReconciler) Zones() (map[string]azureZone, error) {
+	raw, err := r.backend.rawZones()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]azureZone{}
+	for _, zone := range raw {
+		if !r.domainFilter.Match(zone.name) {
+			continue
+		}
+		if zone.id != "" && !r.zoneIDFilter.Match(zone.id) {
+			continue
+		}
+		if !r.backend.zoneUsable(zone.name) {
+			continue
+		}
+		result[zone.name] = zone
+	}
+
+	return result, nil
+}
+
+// Records gets the current records from every zone matching the provider's filters.
+func (r *azureReconciler) Records() ([]*endpoint.Endpoint, error) {
+	zones, err := r.Zones()
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []*endpoint.Endpoint
+	for zoneName := range zones {
+		records, err := r.backend.listRecords(zoneName)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, records...)
+	}
+
+	return endpoints, nil
+}
+
+// ApplyChanges applies a given set of changes. Endpoints are grouped by the record set they
+// belong to so that, say, several A records round-robining under one name reach ARM as a single
+// CreateOrUpdate call, and zones are reconciled concurrently (bounded by maxConcurrentZoneUpdates)
+// since each zone's changes are independent of every other zone's.
+func (r *azureReconciler) ApplyChanges(changes *plan.Changes) error {
+	changes = plan.Filter(changes, azureCapabilities)
+
+	zones, err := r.Zones()
+	if err != nil {
+		return err
+	}
+
+	deletions := r.groupByRecordSet(zones, append(append([]*endpoint.Endpoint{}, changes.UpdateOld...), changes.Delete...))
+	upserts := r.groupByRecordSet(zones, append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...))
+
+	// A key being upserted doesn't need an explicit delete first - CreateOrUpdate replaces the
+	// record set in place, and deleting first would open a real (if brief) resolution gap for
+	// what is really just an in-place update. ACME challenge TXT groups are the exception: there,
+	// a shared key's delete and upsert each act on one value within the record set rather than
+	// the set as a whole, so both sides still need to run.
+	for key, group := range deletions {
+		if group[0].IsACMEChallenge() {
+			continue
+		}
+		if _, upserted := upserts[key]; upserted {
+			delete(deletions, key)
+		}
+	}
+
+	zoneNames := map[string]bool{}
+	for key := range deletions {
+		zoneNames[key.zone] = true
+	}
+	for key := range upserts {
+		zoneNames[key.zone] = true
+	}
+
+	sem := make(chan struct{}, maxConcurrentZoneUpdates)
+	var wg sync.WaitGroup
+	for zoneName := range zoneNames {
+		zoneName := zoneName
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.applyZoneChanges(zoneName, deletions, upserts)
+		}()
+	}
+	wg.Wait()
+
+	r.verifyApplied(changes)
+
+	return nil
+}
+
+// groupByRecordSet buckets endpoints by the Azure record set they belong to.
+func (r *azureReconciler) groupByRecordSet(zones map[string]azureZone, endpoints []*endpoint.Endpoint) map[recordSetKey][]*endpoint.Endpoint {
+	groups := map[recordSetKey][]*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		zoneName, relativeName := suitableZone(ep.DNSName, zones)
+		if zoneName == "" {
+			log.Debugf("Skipping endpoint %v because no hosted zone matching record DNS Name was detected", ep)
+			continue
+		}
+		key := recordSetKey{zone: zoneName, relativeName: relativeName, recordType: ep.RecordType}
+		groups[key] = append(groups[key], ep)
+	}
+	return groups
+}
+
+// applyZoneChanges processes every deletion and upsert belonging to a single zone. ACME
+// challenge TXT values still go through the live-record merge path one value at a time, since
+// whether they coexist with values left over from another reconcile loop can't be determined
+// from this change set alone.
+func (r *azureReconciler) applyZoneChanges(zoneName string, deletions, upserts map[recordSetKey][]*endpoint.Endpoint) {
+	for key, group := range deletions {
+		if key.zone != zoneName {
+			continue
+		}
+		if key.recordType == endpoint.RecordTypeTXT && group[0].IsACMEChallenge() {
+			for _, ep := range group {
+				r.deleteChallengeTXTValue(key.zone, key.relativeName, ep)
+			}
+			continue
+		}
+		r.deleteRecordSet(key)
+	}
+
+	for key, group := range upserts {
+		if key.zone != zoneName {
+			continue
+		}
+		if key.recordType == endpoint.RecordTypeTXT && group[0].IsACMEChallenge() {
+			for _, ep := range group {
+				r.upsertChallengeTXT(key.zone, key.relativeName, ep)
+			}
+			continue
+		}
+		r.upsertRecordSet(key, group)
+	}
+}
+
+// verifyApplied polls the configured verifier, if any, until every created or updated endpoint
+// is observed to have propagated. It never fails ApplyChanges - a slow or unreachable resolver
+// is logged, not treated as a reconciliation error.
+func (r *azureReconciler) verifyApplied(changes *plan.Changes) {
+	if r.verifier == nil || r.dryRun {
+		return
+	}
+
+	for _, ep := range append(append([]*endpoint.Endpoint{}, changes.Create...), changes.UpdateNew...) {
+		if err := r.verifier.Verify(context.Background(), ep.DNSName, ep.RecordType, ep.Target); err != nil {
+			log.Warnf("Failed to verify propagation of %s record '%s': %v", ep.RecordType, ep.DNSName, err)
+		}
+	}
+}
+
+// upsertRecordSet pushes every endpoint sharing key's (zone, name, type) to the backend as a
+// single call, e.g. several A records round-robining under one name become one record set
+// carrying multiple values instead of N overwriting calls.
+func (r *azureReconciler) upsertRecordSet(key recordSetKey, group []*endpoint.Endpoint) {
+	ttl := int64(recordTTL)
+	if group[0].RecordTTL.IsConfigured() {
+		ttl = int64(group[0].RecordTTL)
+	}
+
+	targets := make([]string, len(group))
+	for i, ep := range group {
+		targets[i] = ep.Target
+	}
+
+	if r.dryRun {
+		log.Infof("Would create/update %s record named '%s' to %v for Azure DNS zone '%s'.", key.recordType, key.relativeName, targets, key.zone)
+		return
+	}
+
+	log.Infof("Creating/updating %s record named '%s' to %v for Azure DNS zone '%s'.", key.recordType, key.relativeName, targets, key.zone)
+
+	if err := retryOn429(func() error { return r.backend.upsert(key.zone, key.relativeName, key.recordType, targets, ttl) }); err != nil {
+		log.Errorf("Failed to create/update %s record named '%s' for Azure DNS zone '%s': %v", key.recordType, key.relativeName, key.zone, err)
+	}
+}
+
+// upsertChallengeTXT adds a value to an ACME DNS-01 challenge TXT record set without touching
+// whatever other values are already there, since a client may be mid-rotation with more than
+// one value concurrently live under the same name.
+func (r *azureReconciler) upsertChallengeTXT(zoneName, relativeName string, ep *endpoint.Endpoint) {
+	ttl := int64(endpoint.ChallengeTTL)
+	if ep.RecordTTL.IsConfigured() {
+		ttl = int64(ep.RecordTTL)
+	}
+
+	values := []string{ep.Target}
+	if existing, existingTTL, ok := r.backend.getTXTValues(zoneName, relativeName); ok {
+		values = mergeChallengeValue(existing, ep.Target)
+		ttl = existingTTL
+	}
+
+	if r.dryRun {
+		log.Infof("Would create/update ACME challenge TXT record named '%s' with %d value(s) for Azure DNS zone '%s'.", relativeName, len(values), zoneName)
+		return
+	}
+
+	log.Infof("Creating/updating ACME challenge TXT record named '%s' with %d value(s) for Azure DNS zone '%s'.", relativeName, len(values), zoneName)
+
+	if err := retryOn429(func() error { return r.backend.upsert(zoneName, relativeName, endpoint.RecordTypeTXT, values, ttl) }); err != nil {
+		log.Errorf("Failed to create/update ACME challenge TXT record named '%s' for Azure DNS zone '%s': %v", relativeName, zoneName, err)
+	}
+}
+
+// deleteRecordSet deletes the whole record set identified by key with a single call, regardless
+// of how many endpoints in the change set asked for it to be removed.
+func (r *azureReconciler) deleteRecordSet(key recordSetKey) {
+	if r.dryRun {
+		log.Infof("Would delete %s record named '%s' for Azure DNS zone '%s'.", key.recordType, key.relativeName, key.zone)
+		return
+	}
+
+	log.Infof("Deleting %s record named '%s' for Azure DNS zone '%s'.", key.recordType, key.relativeName, key.zone)
+
+	if err := retryOn429(func() error { return r.backend.delete(key.zone, key.relativeName, key.recordType) }); err != nil {
+		log.Errorf("Failed to delete %s record named '%s' for Azure DNS zone '%s': %v", key.recordType, key.relativeName, key.zone, err)
+	}
+}
+
+// deleteChallengeTXTValue removes a single value from an ACME DNS-01 challenge TXT record set,
+// leaving any other concurrently-live values untouched, and only deletes the record set itself
+// once its last value is gone.
+func (r *azureReconciler) deleteChallengeTXTValue(zoneName, relativeName string, ep *endpoint.Endpoint) {
+	existing, ttl, ok := r.backend.getTXTValues(zoneName, relativeName)
+	if !ok {
+		log.Debugf("ACME challenge TXT record named '%s' for Azure DNS zone '%s' already gone", relativeName, zoneName)
+		return
+	}
+
+	remaining := removeChallengeValue(existing, ep.Target)
+
+	if r.dryRun {
+		log.Infof("Would remove ACME challenge TXT value '%s' from record named '%s' for Azure DNS zone '%s', %d value(s) would remain.", ep.Target, relativeName, zoneName, len(remaining))
+		return
+	}
+
+	if len(remaining) == 0 {
+		log.Infof("Deleting ACME challenge TXT record named '%s' for Azure DNS zone '%s' as its last value was removed.", relativeName, zoneName)
+		if err := retryOn429(func() error { return r.backend.delete(zoneName, relativeName, endpoint.RecordTypeTXT) }); err != nil {
+			log.Errorf("Failed to delete ACME challenge TXT record named '%s' for Azure DNS zone '%s': %v", relativeName, zoneName, err)
+		}
+		return
+	}
+
+	log.Infof("Removing ACME challenge TXT value '%s' from record named '%s' for Azure DNS zone '%s', %d value(s) remain.", ep.Target, relativeName, zoneName, len(remaining))
+
+	if err := retryOn429(func() error { return r.backend.upsert(zoneName, relativeName, endpoint.RecordTypeTXT, remaining, ttl) }); err != nil {
+		log.Errorf("Failed to update ACME challenge TXT record named '%s' for Azure DNS zone '%s': %v", relativeName, zoneName, err)
+	}
+}
+
+func mergeChallengeValue(existing []string, value string) []string {
+	for _, v := range existing {
+		if v == value {
+			return existing
+		}
+	}
+	return append(existing, value)
+}
+
+func removeChallengeValue(existing []string, value string) []string {
+	var remaining []string
+	for _, v := range existing {
+		if v != value {
+			remaining = append(remaining, v)
+		}
+	}
+	return remaining
+}
+
+// suitableZone returns the name of the longest matching zone for a given hostname, along with
+// the relative record set name within that zone.
+func suitableZone(hostname string, zones map[string]azureZone) (zoneName string, relativeName string) {
+	for zone := range zones {
+		if hostname == zone || strings.HasSuffix(hostname, "."+zone) {
+			if len(zone) > len(zoneName) {
+				zoneName = zone
+			}
+		}
+	}
+	if zoneName == "" {
+		return "", ""
+	}
+	if hostname == zoneName {
+		return zoneName, "@"
+	}
+	return zoneName, strings.TrimSuffix(hostname, "."+zoneName)
+}
+
+// formatAzureDNSName transforms a relative record set name into a fully qualified name
+func formatAzureDNSName(relativeRecordSetName, zoneName string) string {
+	if relativeRecordSetName == "@" {
+		return zoneName
+	}
+	return fmt.Sprintf("%s.%s", relativeRecordSetName, zoneName)
+}
+
+// azurePublicBackend implements azureBackend against the public Azure DNS control plane.
+type azurePublicBackend struct {
+	resourceGroup string
+	zonesClient   ZonesClient
+	recordsClient RecordsClient
+}
+
+func (b *azurePublicBackend) rawZones() ([]azureZone, error) {
+	var zones []azureZone
+
+	list, err := b.zonesClient.ListByResourceGroup(b.resourceGroup, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, zone := range azureZones(list) {
+			if zone.Name == nil {
+				continue
+			}
+			id := ""
+			if zone.ID != nil {
+				id = *zone.ID
+			}
+			zones = append(zones, azureZone{name: *zone.Name, id: id})
+		}
+
+		if list.NextLink == nil {
+			break
+		}
+		list, err = b.zonesClient.ListByResourceGroupNextResults(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return zones, nil
+}
+
+func (b *azurePublicBackend) zoneUsable(zoneName string) bool {
+	return true
+}
+
+func azureZones(list dns.ZoneListResult) []dns.Zone {
+	if list.Value == nil {
+		return nil
+	}
+	return *list.Value
+}
+
+func (b *azurePublicBackend) listRecords(zoneName string) ([]*endpoint.Endpoint, error) {
+	var endpoints []*endpoint.Endpoint
+
+	list, err := b.recordsClient.ListByDNSZone(b.resourceGroup, zoneName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, recordSet := range azureRecordSets(list) {
+			if recordSet.Name == nil || recordSet.Type == nil {
+				continue
+			}
+			recordType := strings.TrimPrefix(*recordSet.Type, "Microsoft.Network/dnszones/")
+			if !azureDNSTypes[recordType] {
+				continue
+			}
+
+			name := formatAzureDNSName(*recordSet.Name, zoneName)
+
+			var ttl endpoint.TTL
+			if recordSet.TTL != nil {
+				ttl = endpoint.TTL(*recordSet.TTL)
+			}
+
+			if recordType == endpoint.RecordTypeTXT && endpoint.IsACMEChallengeName(name) {
+				// ACME DNS-01 challenges can have several concurrent values under the same
+				// name while a client is mid-rotation, so surface one endpoint per value
+				// rather than collapsing them into a single target.
+				for _, value := range existingTxtValues(&recordSet) {
+					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(name, value, recordType, ttl).WithACMEChallenge())
+				}
+				continue
+			}
+
+			// A and TXT record sets can carry more than one value (round-robin A records,
+			// or TXT records split across several TxtRecord entries); surface one endpoint
+			// per value so ApplyChanges can group them back into a single record set later.
+			for _, target := range extractAzureTargets(&recordSet) {
+				endpoints = append(endpoints, endpoint.NewEndpointWithTTL(name, target, recordType, ttl))
+			}
+		}
+
+		if list.NextLink == nil {
+			break
+		}
+		list, err = b.recordsClient.ListByDNSZoneNextResults(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return endpoints, nil
+}
+
+func azureRecordSets(list dns.RecordSetListResult) []dns.RecordSet {
+	if list.Value == nil {
+		return nil
+	}
+	return *list.Value
+}
+
+func (b *azurePublicBackend) getTXTValues(zoneName, relativeName string) ([]string, int64, bool) {
+	existing, err := b.recordsClient.Get(b.resourceGroup, zoneName, relativeName, dns.TXT)
+	if err != nil {
+		return nil, 0, false
+	}
+	ttl := int64(endpoint.ChallengeTTL)
+	if existing.TTL != nil {
+		ttl = *existing.TTL
+	}
+	return existingTxtValues(&existing), ttl, true
+}
+
+func (b *azurePublicBackend) upsert(zoneName, relativeName, recordType string, targets []string, ttl int64) error {
+	recordSet, err := newRecordSetFromTargets(recordType, targets, ttl)
+	if err != nil {
+		return err
+	}
+	_, err = b.recordsClient.CreateOrUpdate(b.resourceGroup, zoneName, relativeName, dns.RecordType(recordType), recordSet, "", "")
+	return err
+}
+
+func (b *azurePublicBackend) delete(zoneName, relativeName, recordType string) error {
+	_, err := b.recordsClient.Delete(b.resourceGroup, zoneName, relativeName, dns.RecordType(recordType), "")
+	return err
+}
+
+// existingTxtValues flattens every value carried by a TXT record set, since Azure allows more
+// than one dns.TxtRecord entry (each with its own Value slice) per record set.
+func existingTxtValues(recordSet *dns.RecordSet) []string {
+	if recordSet.RecordSetProperties == nil || recordSet.TxtRecords == nil {
+		return nil
+	}
+	var values []string
+	for _, txt := range *recordSet.TxtRecords {
+		if txt.Value == nil {
+			continue
+		}
+		values = append(values, *txt.Value...)
+	}
+	return values
+}
+
+// newRecordSetFromTargets builds a record set carrying every given target, so that several
+// endpoints sharing one (zone, name, type) - e.g. round-robin A records - are pushed to ARM as
+// one RecordSet rather than one CreateOrUpdate call overwriting the last.
+func newRecordSetFromTargets(recordType string, targets []string, ttl int64) (dns.RecordSet, error) {
+	switch recordType {
+	case endpoint.RecordTypeA:
+		aRecords := make([]dns.ARecord, len(targets))
+		for i, target := range targets {
+			target := target
+			aRecords[i] = dns.ARecord{Ipv4Address: &target}
+		}
+		return dns.RecordSet{
+			RecordSetProperties: &dns.RecordSetProperties{
+				TTL:      &ttl,
+				ARecords: &aRecords,
+			},
+		}, nil
+	case endpoint.RecordTypeCNAME:
+		if len(targets) > 1 {
+			log.Warnf("CNAME record set only supports a single target, using '%s' and ignoring %d other(s)", targets[0], len(targets)-1)
+		}
+		return dns.RecordSet{
+			RecordSetProperties: &dns.RecordSetProperties{
+				TTL:         &ttl,
+				CnameRecord: &dns.CnameRecord{Cname: &targets[0]},
+			},
+		}, nil
+	case endpoint.RecordTypeTXT:
+		txtRecords := make([]dns.TxtRecord, len(targets))
+		for i, target := range targets {
+			target := target
+			txtRecords[i] = dns.TxtRecord{Value: &[]string{target}}
+		}
+		return dns.RecordSet{
+			RecordSetProperties: &dns.RecordSetProperties{
+				TTL:        &ttl,
+				TxtRecords: &txtRecords,
+			},
+		}, nil
+	default:
+		return dns.RecordSet{}, fmt.Errorf("unsupported record type '%s'", recordType)
+	}
+}
+
+// extractAzureTargets returns every target carried by an Azure record set. A and TXT record
+// sets can carry more than one concurrently (round-robin A records, or TXT split across several
+// TxtRecord entries); CNAME carries at most one.
+func extractAzureTargets(recordSet *dns.RecordSet) []string {
+	properties := recordSet.RecordSetProperties
+	if properties == nil {
+		return nil
+	}
+
+	if aRecords := properties.ARecords; aRecords != nil {
+		var targets []string
+		for _, a := range *aRecords {
+			if a.Ipv4Address != nil {
+				targets = append(targets, *a.Ipv4Address)
+			}
+		}
+		return targets
+	}
+
+	if cnameRecord := properties.CnameRecord; cnameRecord != nil && cnameRecord.Cname != nil {
+		return []string{*cnameRecord.Cname}
+	}
+
+	if txtRecords := properties.TxtRecords; txtRecords != nil {
+		var targets []string
+		for _, txt := range *txtRecords {
+			if txt.Value != nil && len(*txt.Value) > 0 {
+				targets = append(targets, (*txt.Value)[0])
+			}
+		}
+		return targets
+	}
+
+	return nil
+}
+
+// azurePrivateBackend implements azureBackend against the Azure Private DNS control plane.
+type azurePrivateBackend struct {
+	resourceGroup string
+	zonesClient   PrivateZonesClient
+	recordsClient PrivateRecordsClient
+}
+
+func (b *azurePrivateBackend) rawZones() ([]azureZone, error) {
+	var zones []azureZone
+
+	list, err := b.zonesClient.ListByResourceGroup(b.resourceGroup, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, zone := range azurePrivateZones(list) {
+			if zone.Name == nil {
+				continue
+			}
+			id := ""
+			if zone.ID != nil {
+				id = *zone.ID
+			}
+			zones = append(zones, azureZone{name: *zone.Name, id: id})
+		}
+
+		if list.NextLink == nil {
+			break
+		}
+		list, err = b.zonesClient.ListByResourceGroupNextResults(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return zones, nil
+}
+
+// zoneUsable reports whether a private zone is linked to at least one virtual network, which is
+// what makes it actually resolvable for anyone.
+func (b *azurePrivateBackend) zoneUsable(zoneName string) bool {
+	links, err := b.zonesClient.ListVirtualNetworkLinks(b.resourceGroup, zoneName, nil)
+	if err != nil {
+		log.Errorf("Failed to list virtual network links for private zone '%s': %v", zoneName, err)
+		return false
+	}
+	if links.Value == nil || len(*links.Value) == 0 {
+		log.Debugf("Skipping private zone '%s' because it has no virtual network links", zoneName)
+		return false
+	}
+	return true
+}
+
+func azurePrivateZones(list privatedns.PrivateZoneListResult) []privatedns.PrivateZone {
+	if list.Value == nil {
+		return nil
+	}
+	return *list.Value
+}
+
+func (b *azurePrivateBackend) listRecords(zoneName string) ([]*endpoint.Endpoint, error) {
+	var endpoints []*endpoint.Endpoint
+
+	list, err := b.recordsClient.ListByDNSZone(b.resourceGroup, zoneName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, recordSet := range azurePrivateRecordSets(list) {
+			if recordSet.Name == nil || recordSet.Type == nil {
+				continue
+			}
+			recordType := strings.TrimPrefix(*recordSet.Type, "Microsoft.Network/privateDnsZones/")
+			if !azureDNSTypes[recordType] {
+				continue
+			}
+
+			name := formatAzureDNSName(*recordSet.Name, zoneName)
+
+			var ttl endpoint.TTL
+			if recordSet.TTL != nil {
+				ttl = endpoint.TTL(*recordSet.TTL)
+			}
+
+			if recordType == endpoint.RecordTypeTXT && endpoint.IsACMEChallengeName(name) {
+				for _, value := range existingPrivateTxtValues(&recordSet) {
+					endpoints = append(endpoints, endpoint.NewEndpointWithTTL(name, value, recordType, ttl).WithACMEChallenge())
+				}
+				continue
+			}
+
+			for _, target := range extractAzurePrivateTargets(&recordSet) {
+				endpoints = append(endpoints, endpoint.NewEndpointWithTTL(name, target, recordType, ttl))
+			}
+		}
+
+		if list.NextLink == nil {
+			break
+		}
+		list, err = b.recordsClient.ListByDNSZoneNextResults(list)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return endpoints, nil
+}
+
+func azurePrivateRecordSets(list privatedns.RecordSetListResult) []privatedns.RecordSet {
+	if list.Value == nil {
+		return nil
+	}
+	return *list.Value
+}
+
+func (b *azurePrivateBackend) getTXTValues(zoneName, relativeName string) ([]string, int64, bool) {
+	existing, err := b.recordsClient.Get(b.resourceGroup, zoneName, relativeName, privatedns.TXT)
+	if err != nil {
+		return nil, 0, false
+	}
+	ttl := int64(endpoint.ChallengeTTL)
+	if existing.TTL != nil {
+		ttl = *existing.TTL
+	}
+	return existingPrivateTxtValues(&existing), ttl, true
+}
+
+func (b *azurePrivateBackend) upsert(zoneName, relativeName, recordType string, targets []string, ttl int64) error {
+	recordSet, err := newPrivateRecordSetFromTargets(recordType, targets, ttl)
+	if err != nil {
+		return err
+	}
+	_, err = b.recordsClient.CreateOrUpdate(b.resourceGroup, zoneName, relativeName, privatedns.RecordType(recordType), recordSet, "", "")
+	return err
+}
+
+func (b *azurePrivateBackend) delete(zoneName, relativeName, recordType string) error {
+	_, err := b.recordsClient.Delete(b.resourceGroup, zoneName, relativeName, privatedns.RecordType(recordType), "")
+	return err
+}
+
+// existingPrivateTxtValues flattens every value carried by a private TXT record set, the
+// privatedns equivalent of existingTxtValues.
+func existingPrivateTxtValues(recordSet *privatedns.RecordSet) []string {
+	if recordSet.RecordSetProperties == nil || recordSet.TxtRecords == nil {
+		return nil
+	}
+	var values []string
+	for _, txt := range *recordSet.TxtRecords {
+		if txt.Value == nil {
+			continue
+		}
+		values = append(values, *txt.Value...)
+	}
+	return values
+}
+
+// newPrivateRecordSetFromTargets is the privatedns equivalent of newRecordSetFromTargets.
+func newPrivateRecordSetFromTargets(recordType string, targets []string, ttl int64) (privatedns.RecordSet, error) {
+	switch recordType {
+	case endpoint.RecordTypeA:
+		aRecords := make([]privatedns.ARecord, len(targets))
+		for i, target := range targets {
+			target := target
+			aRecords[i] = privatedns.ARecord{Ipv4Address: &target}
+		}
+		return privatedns.RecordSet{
+			RecordSetProperties: &privatedns.RecordSetProperties{
+				TTL:      &ttl,
+				ARecords: &aRecords,
+			},
+		}, nil
+	case endpoint.RecordTypeCNAME:
+		if len(targets) > 1 {
+			log.Warnf("CNAME record set only supports a single target, using '%s' and ignoring %d other(s)", targets[0], len(targets)-1)
+		}
+		return privatedns.RecordSet{
+			RecordSetProperties: &privatedns.RecordSetProperties{
+				TTL:         &ttl,
+				CnameRecord: &privatedns.CnameRecord{Cname: &targets[0]},
+			},
+		}, nil
+	case endpoint.RecordTypeTXT:
+		txtRecords := make([]privatedns.TxtRecord, len(targets))
+		for i, target := range targets {
+			target := target
+			txtRecords[i] = privatedns.TxtRecord{Value: &[]string{target}}
+		}
+		return privatedns.RecordSet{
+			RecordSetProperties: &privatedns.RecordSetProperties{
+				TTL:        &ttl,
+				TxtRecords: &txtRecords,
+			},
+		}, nil
+	default:
+		return privatedns.RecordSet{}, fmt.Errorf("unsupported record type '%s'", recordType)
+	}
+}
+
+// extractAzurePrivateTargets is the privatedns equivalent of extractAzureTargets.
+func extractAzurePrivateTargets(recordSet *privatedns.RecordSet) []string {
+	properties := recordSet.RecordSetProperties
+	if properties == nil {
+		return nil
+	}
+
+	if aRecords := properties.ARecords; aRecords != nil {
+		var targets []string
+		for _, a := range *aRecords {
+			if a.Ipv4Address != nil {
+				targets = append(targets, *a.Ipv4Address)
+			}
+		}
+		return targets
+	}
+
+	if cnameRecord := properties.CnameRecord; cnameRecord != nil && cnameRecord.Cname != nil {
+		return []string{*cnameRecord.Cname}
+	}
+
+	if txtRecords := properties.TxtRecords; txtRecords != nil {
+		var targets []string
+		for _, txt := range *txtRecords {
+			if txt.Value != nil && len(*txt.Value) > 0 {
+				targets = append(targets, (*txt.Value)[0])
+			}
+		}
+		return targets
+	}
+
+	return nil
+}
@@ -17,9 +17,14 @@ limitations under the License.
 package provider
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/arm/dns"
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns"
+	"github.com/Azure/azure-sdk-for-go/services/privatedns/mgmt/2018-09-01/privatedns"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
 
@@ -34,9 +39,16 @@ type mockZonesClient struct {
 }
 
 type mockRecordsClient struct {
-	mockRecordSet    *[]dns.RecordSet
-	deletedEndpoints []*endpoint.Endpoint
-	updatedEndpoints []*endpoint.Endpoint
+	mu sync.Mutex
+
+	mockRecordSet *[]dns.RecordSet
+	// mockRecordSetPage2, when set, is returned by ListByDNSZoneNextResults once, to exercise
+	// the Records() paging loop the way a zone with thousands of records would page in practice.
+	mockRecordSetPage2 *[]dns.RecordSet
+
+	deletedEndpoints    []*endpoint.Endpoint
+	updatedEndpoints    []*endpoint.Endpoint
+	createOrUpdateCalls int
 }
 
 func createMockZone(zone string, id string) dns.Zone {
@@ -117,14 +129,48 @@ func createMockRecordSetWithTTL(name, recordType, value string, ttl int64) dns.R
 }
 
 func (client *mockRecordsClient) ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (dns.RecordSetListResult, error) {
-	return dns.RecordSetListResult{Value: client.mockRecordSet}, nil
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	result := dns.RecordSetListResult{Value: client.mockRecordSet}
+	if client.mockRecordSetPage2 != nil {
+		result.NextLink = to.StringPtr("page2")
+	}
+	return result, nil
 }
 
+// ListByDNSZoneNextResults hands back the second page registered via mockRecordSetPage2, if
+// any, exactly once, mirroring how ARM stops returning a NextLink once a zone is exhausted.
 func (client *mockRecordsClient) ListByDNSZoneNextResults(list dns.RecordSetListResult) (dns.RecordSetListResult, error) {
-	return dns.RecordSetListResult{}, nil
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.mockRecordSetPage2 == nil {
+		return dns.RecordSetListResult{}, nil
+	}
+	page2 := client.mockRecordSetPage2
+	client.mockRecordSetPage2 = nil
+	return dns.RecordSetListResult{Value: page2}, nil
+}
+
+func (client *mockRecordsClient) Get(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType) (dns.RecordSet, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.mockRecordSet != nil {
+		for _, rs := range *client.mockRecordSet {
+			if rs.Name != nil && *rs.Name == relativeRecordSetName && rs.Type != nil && strings.HasSuffix(*rs.Type, string(recordType)) {
+				return rs, nil
+			}
+		}
+	}
+	return dns.RecordSet{}, fmt.Errorf("record set %s/%s not found", relativeRecordSetName, recordType)
 }
 
 func (client *mockRecordsClient) Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, ifMatch string) (autorest.Response, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
 	client.deletedEndpoints = append(
 		client.deletedEndpoints,
 		endpoint.NewEndpoint(
@@ -133,34 +179,83 @@ func (client *mockRecordsClient) Delete(resourceGroupName string, zoneName strin
 			string(recordType),
 		),
 	)
+	client.removeMockRecordSet(relativeRecordSetName, recordType)
 	return autorest.Response{}, nil
 }
 
+// CreateOrUpdate records one updatedEndpoints entry per target carried by parameters, so tests
+// can assert that several endpoints sharing a name were pushed as a single multi-value call
+// rather than as separate overwriting calls.
 func (client *mockRecordsClient) CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType dns.RecordType, parameters dns.RecordSet, ifMatch string, ifNoneMatch string) (dns.RecordSet, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.createOrUpdateCalls++
+
 	var ttl endpoint.TTL
 	if parameters.TTL != nil {
 		ttl = endpoint.TTL(*parameters.TTL)
 	}
-	client.updatedEndpoints = append(
-		client.updatedEndpoints,
-		endpoint.NewEndpointWithTTL(
-			formatAzureDNSName(relativeRecordSetName, zoneName),
-			extractAzureTarget(&parameters),
-			string(recordType),
-			ttl,
-		),
-	)
+	for _, target := range extractAzureTargets(&parameters) {
+		client.updatedEndpoints = append(
+			client.updatedEndpoints,
+			endpoint.NewEndpointWithTTL(
+				formatAzureDNSName(relativeRecordSetName, zoneName),
+				target,
+				string(recordType),
+				ttl,
+			),
+		)
+	}
+	parameters.Name = to.StringPtr(relativeRecordSetName)
+	parameters.Type = to.StringPtr("Microsoft.Network/dnszones/" + string(recordType))
+	client.upsertMockRecordSet(parameters)
 	return parameters, nil
 }
 
+func (client *mockRecordsClient) upsertMockRecordSet(rs dns.RecordSet) {
+	var records []dns.RecordSet
+	if client.mockRecordSet != nil {
+		records = *client.mockRecordSet
+	}
+	for i, existing := range records {
+		if existing.Name != nil && rs.Name != nil && *existing.Name == *rs.Name &&
+			existing.Type != nil && rs.Type != nil && *existing.Type == *rs.Type {
+			records[i] = rs
+			client.mockRecordSet = &records
+			return
+		}
+	}
+	records = append(records, rs)
+	client.mockRecordSet = &records
+}
+
+func (client *mockRecordsClient) removeMockRecordSet(relativeRecordSetName string, recordType dns.RecordType) {
+	if client.mockRecordSet == nil {
+		return
+	}
+	var remaining []dns.RecordSet
+	for _, rs := range *client.mockRecordSet {
+		if rs.Name != nil && *rs.Name == relativeRecordSetName && rs.Type != nil && strings.HasSuffix(*rs.Type, string(recordType)) {
+			continue
+		}
+		remaining = append(remaining, rs)
+	}
+	client.mockRecordSet = &remaining
+}
+
 func newAzureProvider(domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, dryRun bool, resourceGroup string, zonesClient ZonesClient, recordsClient RecordsClient) *AzureProvider {
 	return &AzureProvider{
-		domainFilter:  domainFilter,
-		zoneIDFilter:  zoneIDFilter,
-		dryRun:        dryRun,
-		resourceGroup: resourceGroup,
-		zonesClient:   zonesClient,
-		recordsClient: recordsClient,
+		azureReconciler: &azureReconciler{
+			domainFilter: domainFilter,
+			zoneIDFilter: zoneIDFilter,
+			dryRun:       dryRun,
+			backend: &azurePublicBackend{
+				resourceGroup: resourceGroup,
+				zonesClient:   zonesClient,
+				recordsClient: recordsClient,
+			},
+		},
 	}
 }
 
@@ -234,6 +329,37 @@ func TestAzureApplyChanges(t *testing.T) {
 	})
 }
 
+// TestAzureApplyChangesFiltersUnsupportedRecordType checks that ApplyChanges itself strips
+// changes for record types azureCapabilities doesn't advertise, rather than relying on callers
+// to pre-filter with plan.Filter.
+func TestAzureApplyChangesFiltersUnsupportedRecordType(t *testing.T) {
+	recordsClient := mockRecordsClient{}
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}), NewZoneIDFilter([]string{""}), false, "group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("unsupported.example.com", "::1", "AAAA"),
+			endpoint.NewEndpoint("www.example.com", "1.2.3.4", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", "1.2.3.4", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+	})
+}
+
 func TestAzureApplyChangesDryRun(t *testing.T) {
 	recordsClient := mockRecordsClient{}
 
@@ -302,3 +428,751 @@ func testAzureApplyChangesInternal(t *testing.T, dryRun bool, client RecordsClie
 		t.Fatal(err)
 	}
 }
+
+type mockPrivateZonesClient struct {
+	mockZoneListResult *privatedns.PrivateZoneListResult
+	linkedZones        map[string]bool
+}
+
+type mockPrivateRecordsClient struct {
+	mu sync.Mutex
+
+	mockRecordSet       *[]privatedns.RecordSet
+	deletedEndpoints    []*endpoint.Endpoint
+	updatedEndpoints    []*endpoint.Endpoint
+	createOrUpdateCalls int
+}
+
+func createMockPrivateZone(zone string, id string) privatedns.PrivateZone {
+	return privatedns.PrivateZone{
+		ID:   to.StringPtr(id),
+		Name: to.StringPtr(zone),
+	}
+}
+
+func (client *mockPrivateZonesClient) ListByResourceGroup(resourceGroupName string, top *int32) (privatedns.PrivateZoneListResult, error) {
+	return *client.mockZoneListResult, nil
+}
+
+func (client *mockPrivateZonesClient) ListByResourceGroupNextResults(lastResults privatedns.PrivateZoneListResult) (privatedns.PrivateZoneListResult, error) {
+	return privatedns.PrivateZoneListResult{}, nil
+}
+
+func (client *mockPrivateZonesClient) ListVirtualNetworkLinks(resourceGroupName string, privateZoneName string, top *int32) (privatedns.VirtualNetworkLinkListResult, error) {
+	if !client.linkedZones[privateZoneName] {
+		return privatedns.VirtualNetworkLinkListResult{Value: &[]privatedns.VirtualNetworkLink{}}, nil
+	}
+	return privatedns.VirtualNetworkLinkListResult{
+		Value: &[]privatedns.VirtualNetworkLink{
+			{Name: to.StringPtr(privateZoneName + "-link")},
+		},
+	}, nil
+}
+
+func aPrivateRecordSetPropertiesGetter(value string, ttl int64) *privatedns.RecordSetProperties {
+	return &privatedns.RecordSetProperties{
+		TTL: to.Int64Ptr(ttl),
+		ARecords: &[]privatedns.ARecord{
+			{
+				Ipv4Address: to.StringPtr(value),
+			},
+		},
+	}
+}
+
+func txtPrivateRecordSetPropertiesGetter(value string, ttl int64) *privatedns.RecordSetProperties {
+	return &privatedns.RecordSetProperties{
+		TTL: to.Int64Ptr(ttl),
+		TxtRecords: &[]privatedns.TxtRecord{
+			{
+				Value: &[]string{value},
+			},
+		},
+	}
+}
+
+func createMockPrivateRecordSet(name, recordType, value string) privatedns.RecordSet {
+	return createMockPrivateRecordSetWithTTL(name, recordType, value, 0)
+}
+
+func createMockPrivateRecordSetWithTTL(name, recordType, value string, ttl int64) privatedns.RecordSet {
+	var getterFunc func(value string, ttl int64) *privatedns.RecordSetProperties
+
+	switch recordType {
+	case endpoint.RecordTypeA:
+		getterFunc = aPrivateRecordSetPropertiesGetter
+	case endpoint.RecordTypeTXT:
+		getterFunc = txtPrivateRecordSetPropertiesGetter
+	default:
+		getterFunc = func(value string, ttl int64) *privatedns.RecordSetProperties {
+			return &privatedns.RecordSetProperties{TTL: to.Int64Ptr(ttl)}
+		}
+	}
+	return privatedns.RecordSet{
+		Name:                to.StringPtr(name),
+		Type:                to.StringPtr("Microsoft.Network/privateDnsZones/" + recordType),
+		RecordSetProperties: getterFunc(value, ttl),
+	}
+}
+
+func (client *mockPrivateRecordsClient) ListByDNSZone(resourceGroupName string, zoneName string, top *int32) (privatedns.RecordSetListResult, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return privatedns.RecordSetListResult{Value: client.mockRecordSet}, nil
+}
+
+func (client *mockPrivateRecordsClient) ListByDNSZoneNextResults(list privatedns.RecordSetListResult) (privatedns.RecordSetListResult, error) {
+	return privatedns.RecordSetListResult{}, nil
+}
+
+func (client *mockPrivateRecordsClient) Get(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType privatedns.RecordType) (privatedns.RecordSet, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.mockRecordSet != nil {
+		for _, rs := range *client.mockRecordSet {
+			if rs.Name != nil && *rs.Name == relativeRecordSetName && rs.Type != nil && strings.HasSuffix(*rs.Type, string(recordType)) {
+				return rs, nil
+			}
+		}
+	}
+	return privatedns.RecordSet{}, fmt.Errorf("record set %s/%s not found", relativeRecordSetName, recordType)
+}
+
+func (client *mockPrivateRecordsClient) Delete(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType privatedns.RecordType, ifMatch string) (autorest.Response, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.deletedEndpoints = append(
+		client.deletedEndpoints,
+		endpoint.NewEndpoint(
+			formatAzureDNSName(relativeRecordSetName, zoneName),
+			"",
+			string(recordType),
+		),
+	)
+	client.removeMockRecordSet(relativeRecordSetName, recordType)
+	return autorest.Response{}, nil
+}
+
+// CreateOrUpdate records one updatedEndpoints entry per target carried by parameters, the
+// privatedns equivalent of mockRecordsClient.CreateOrUpdate.
+func (client *mockPrivateRecordsClient) CreateOrUpdate(resourceGroupName string, zoneName string, relativeRecordSetName string, recordType privatedns.RecordType, parameters privatedns.RecordSet, ifMatch string, ifNoneMatch string) (privatedns.RecordSet, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.createOrUpdateCalls++
+
+	var ttl endpoint.TTL
+	if parameters.TTL != nil {
+		ttl = endpoint.TTL(*parameters.TTL)
+	}
+	for _, target := range extractAzurePrivateTargets(&parameters) {
+		client.updatedEndpoints = append(
+			client.updatedEndpoints,
+			endpoint.NewEndpointWithTTL(
+				formatAzureDNSName(relativeRecordSetName, zoneName),
+				target,
+				string(recordType),
+				ttl,
+			),
+		)
+	}
+	parameters.Name = to.StringPtr(relativeRecordSetName)
+	parameters.Type = to.StringPtr("Microsoft.Network/privateDnsZones/" + string(recordType))
+	client.upsertMockRecordSet(parameters)
+	return parameters, nil
+}
+
+func (client *mockPrivateRecordsClient) upsertMockRecordSet(rs privatedns.RecordSet) {
+	var records []privatedns.RecordSet
+	if client.mockRecordSet != nil {
+		records = *client.mockRecordSet
+	}
+	for i, existing := range records {
+		if existing.Name != nil && rs.Name != nil && *existing.Name == *rs.Name &&
+			existing.Type != nil && rs.Type != nil && *existing.Type == *rs.Type {
+			records[i] = rs
+			client.mockRecordSet = &records
+			return
+		}
+	}
+	records = append(records, rs)
+	client.mockRecordSet = &records
+}
+
+func (client *mockPrivateRecordsClient) removeMockRecordSet(relativeRecordSetName string, recordType privatedns.RecordType) {
+	if client.mockRecordSet == nil {
+		return
+	}
+	var remaining []privatedns.RecordSet
+	for _, rs := range *client.mockRecordSet {
+		if rs.Name != nil && *rs.Name == relativeRecordSetName && rs.Type != nil && strings.HasSuffix(*rs.Type, string(recordType)) {
+			continue
+		}
+		remaining = append(remaining, rs)
+	}
+	client.mockRecordSet = &remaining
+}
+
+func newAzurePrivateDNSProvider(domainFilter DomainFilter, zoneIDFilter ZoneIDFilter, dryRun bool, resourceGroup string, zonesClient PrivateZonesClient, recordsClient PrivateRecordsClient) *AzurePrivateDNSProvider {
+	return &AzurePrivateDNSProvider{
+		azureReconciler: &azureReconciler{
+			domainFilter: domainFilter,
+			zoneIDFilter: zoneIDFilter,
+			dryRun:       dryRun,
+			backend: &azurePrivateBackend{
+				resourceGroup: resourceGroup,
+				zonesClient:   zonesClient,
+				recordsClient: recordsClient,
+			},
+		},
+	}
+}
+
+func TestAzurePrivateDNSRecord(t *testing.T) {
+	zonesClient := mockPrivateZonesClient{
+		mockZoneListResult: &privatedns.PrivateZoneListResult{
+			Value: &[]privatedns.PrivateZone{
+				createMockPrivateZone("example.com", "/privateDnsZones/example.com"),
+			},
+		},
+		linkedZones: map[string]bool{"example.com": true},
+	}
+
+	recordsClient := mockPrivateRecordsClient{
+		mockRecordSet: &[]privatedns.RecordSet{
+			createMockPrivateRecordSet("@", "SOA", "Email: azuredns-hostmaster.microsoft.com"),
+			createMockPrivateRecordSet("@", endpoint.RecordTypeA, "123.123.123.122"),
+			createMockPrivateRecordSetWithTTL("nginx", endpoint.RecordTypeA, "123.123.123.123", 3600),
+		},
+	}
+
+	provider := newAzurePrivateDNSProvider(NewDomainFilter([]string{"example.com"}), NewZoneIDFilter([]string{""}), true, "k8s", &zonesClient, &recordsClient)
+
+	actual, err := provider.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", "123.123.123.122", endpoint.RecordTypeA),
+		endpoint.NewEndpointWithTTL("nginx.example.com", "123.123.123.123", endpoint.RecordTypeA, 3600),
+	}
+
+	validateAzureEndpoints(t, actual, expected)
+}
+
+func TestAzurePrivateDNSRecordSkipsUnlinkedZones(t *testing.T) {
+	zonesClient := mockPrivateZonesClient{
+		mockZoneListResult: &privatedns.PrivateZoneListResult{
+			Value: &[]privatedns.PrivateZone{
+				createMockPrivateZone("linked.com", "/privateDnsZones/linked.com"),
+				createMockPrivateZone("unlinked.com", "/privateDnsZones/unlinked.com"),
+			},
+		},
+		linkedZones: map[string]bool{"linked.com": true},
+	}
+
+	recordsClient := mockPrivateRecordsClient{
+		mockRecordSet: &[]privatedns.RecordSet{
+			createMockPrivateRecordSet("@", endpoint.RecordTypeA, "123.123.123.122"),
+		},
+	}
+
+	provider := newAzurePrivateDNSProvider(NewDomainFilter([]string{""}), NewZoneIDFilter([]string{""}), true, "k8s", &zonesClient, &recordsClient)
+
+	zones, err := provider.Zones()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := zones["linked.com"]; !ok {
+		t.Error("expected linked.com to be considered")
+	}
+	if _, ok := zones["unlinked.com"]; ok {
+		t.Error("expected unlinked.com to be filtered out because it has no virtual network links")
+	}
+}
+
+func TestAzurePrivateDNSApplyChanges(t *testing.T) {
+	recordsClient := mockPrivateRecordsClient{}
+
+	provider := newAzurePrivateDNSProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockPrivateZonesClient{
+			mockZoneListResult: &privatedns.PrivateZoneListResult{
+				Value: &[]privatedns.PrivateZone{
+					createMockPrivateZone("example.com", "/privateDnsZones/example.com"),
+				},
+			},
+			linkedZones: map[string]bool{"example.com": true},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("new.example.com", "111.222.111.222", endpoint.RecordTypeA),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("old.example.com", "121.212.121.212", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	validateAzureEndpoints(t, recordsClient.deletedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.example.com", "", endpoint.RecordTypeA),
+	})
+
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("new.example.com", "111.222.111.222", endpoint.RecordTypeA, recordTTL),
+	})
+}
+
+// TestAzurePrivateDNSApplyChangesBatchesRecordSet checks that AzurePrivateDNSProvider batches
+// several endpoints sharing one (zone, name, type) into a single CreateOrUpdate call, the same
+// way AzureProvider does - both now go through the shared azureReconciler.
+func TestAzurePrivateDNSApplyChangesBatchesRecordSet(t *testing.T) {
+	recordsClient := mockPrivateRecordsClient{}
+	provider := newAzurePrivateDNSProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockPrivateZonesClient{
+			mockZoneListResult: &privatedns.PrivateZoneListResult{
+				Value: &[]privatedns.PrivateZone{createMockPrivateZone("example.com", "/privateDnsZones/example.com")},
+			},
+			linkedZones: map[string]bool{"example.com": true},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("round-robin.example.com", "1.2.3.4", endpoint.RecordTypeA),
+			endpoint.NewEndpoint("round-robin.example.com", "5.6.7.8", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, recordsClient.createOrUpdateCalls == 1, "expected a single CreateOrUpdate call for endpoints sharing one record set, got %d", recordsClient.createOrUpdateCalls)
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("round-robin.example.com", "1.2.3.4", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+		endpoint.NewEndpointWithTTL("round-robin.example.com", "5.6.7.8", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+	})
+}
+
+// TestAzurePrivateDNSApplyChangesACMEChallengeCoexist checks that adding a new ACME DNS-01
+// challenge value next to an existing one preserves both against the private control plane too,
+// mirroring TestAzureApplyChangesACMEChallengeCoexist.
+func TestAzurePrivateDNSApplyChangesACMEChallengeCoexist(t *testing.T) {
+	txtRecords := []privatedns.TxtRecord{{Value: &[]string{"existing-token"}}}
+	recordsClient := mockPrivateRecordsClient{
+		mockRecordSet: &[]privatedns.RecordSet{
+			{
+				Name: to.StringPtr("_acme-challenge.foo"),
+				Type: to.StringPtr("Microsoft.Network/privateDnsZones/TXT"),
+				RecordSetProperties: &privatedns.RecordSetProperties{
+					TTL:        to.Int64Ptr(int64(endpoint.ChallengeTTL)),
+					TxtRecords: &txtRecords,
+				},
+			},
+		},
+	}
+
+	provider := newAzurePrivateDNSProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockPrivateZonesClient{
+			mockZoneListResult: &privatedns.PrivateZoneListResult{
+				Value: &[]privatedns.PrivateZone{createMockPrivateZone("example.com", "/privateDnsZones/example.com")},
+			},
+			linkedZones: map[string]bool{"example.com": true},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("_acme-challenge.foo.example.com", "rotating-token", endpoint.RecordTypeTXT, endpoint.ChallengeTTL).WithACMEChallenge(),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := recordsClient.Get("group", "example.com", "_acme-challenge.foo", privatedns.TXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := existingPrivateTxtValues(&updated)
+	assert.ElementsMatch(t, []string{"existing-token", "rotating-token"}, values, "both challenge values should coexist")
+}
+
+// mockProviderBuilders wires up a mock-backed Provider for every provider name this package
+// registers, so TestProviderCapabilitiesMatrix can drive a canonical sequence of changes
+// against each of them without touching the real Azure control plane.
+var mockProviderBuilders = map[string]func() Provider{
+	"azure": func() Provider {
+		return newAzureProvider(
+			NewDomainFilter([]string{""}), NewZoneIDFilter([]string{""}), false, "group",
+			&mockZonesClient{
+				mockZoneListResult: &dns.ZoneListResult{
+					Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+				},
+			},
+			&mockRecordsClient{},
+		)
+	},
+	"azure-private-dns": func() Provider {
+		return newAzurePrivateDNSProvider(
+			NewDomainFilter([]string{""}), NewZoneIDFilter([]string{""}), false, "group",
+			&mockPrivateZonesClient{
+				mockZoneListResult: &privatedns.PrivateZoneListResult{
+					Value: &[]privatedns.PrivateZone{createMockPrivateZone("example.com", "/privateDnsZones/example.com")},
+				},
+				linkedZones: map[string]bool{"example.com": true},
+			},
+			&mockPrivateRecordsClient{},
+		)
+	},
+}
+
+// TestProviderCapabilitiesMatrix runs a canonical create/update/delete sequence against every
+// registered provider's mock client, asserting that plan.Filter strips changes the provider's
+// declared capabilities say it can't express before ApplyChanges ever sees them.
+func TestProviderCapabilitiesMatrix(t *testing.T) {
+	for _, name := range RegisteredProviders() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			capabilities, ok := CapabilitiesByName(name)
+			if !ok {
+				t.Fatalf("no capabilities registered for %q", name)
+			}
+
+			build, ok := mockProviderBuilders[name]
+			if !ok {
+				t.Fatalf("no mock builder wired up for registered provider %q", name)
+			}
+			p := build()
+
+			changes := &plan.Changes{
+				Create: []*endpoint.Endpoint{
+					endpoint.NewEndpoint("new.example.com", "111.222.111.222", endpoint.RecordTypeA),
+					endpoint.NewEndpoint("new6.example.com", "::1", "AAAA"),
+				},
+				UpdateNew: []*endpoint.Endpoint{
+					endpoint.NewEndpointWithTTL("updated.example.com", "2.2.2.2", endpoint.RecordTypeA, 120),
+				},
+				Delete: []*endpoint.Endpoint{
+					endpoint.NewEndpoint("old.example.com", "1.1.1.1", endpoint.RecordTypeA),
+				},
+			}
+
+			filtered := plan.Filter(changes, capabilities)
+			if len(filtered.Create) != 1 {
+				t.Fatalf("%s: expected the unsupported AAAA record to be filtered out of create changes, got %d", name, len(filtered.Create))
+			}
+			for _, ep := range filtered.Create {
+				if !capabilities.SupportsRecordType(ep.RecordType) {
+					t.Errorf("%s: %s slipped through despite being unsupported", name, ep.RecordType)
+				}
+			}
+
+			if err := p.ApplyChanges(filtered); err != nil {
+				t.Fatalf("%s: ApplyChanges failed: %v", name, err)
+			}
+		})
+	}
+}
+
+func newAzureChallengeRecordSet(values ...string) []dns.TxtRecord {
+	txtRecords := make([]dns.TxtRecord, len(values))
+	for i, value := range values {
+		v := value
+		txtRecords[i] = dns.TxtRecord{Value: &[]string{v}}
+	}
+	return txtRecords
+}
+
+// TestAzureApplyChangesACMEChallengeCoexist verifies that adding a new ACME DNS-01 challenge
+// value next to an existing one preserves both, instead of the new value stomping the old.
+func TestAzureApplyChangesACMEChallengeCoexist(t *testing.T) {
+	txtRecords := newAzureChallengeRecordSet("existing-token")
+	recordsClient := mockRecordsClient{
+		mockRecordSet: &[]dns.RecordSet{
+			{
+				Name: to.StringPtr("_acme-challenge.foo"),
+				Type: to.StringPtr("Microsoft.Network/dnszones/TXT"),
+				RecordSetProperties: &dns.RecordSetProperties{
+					TTL:        to.Int64Ptr(int64(endpoint.ChallengeTTL)),
+					TxtRecords: &txtRecords,
+				},
+			},
+		},
+	}
+
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("_acme-challenge.foo.example.com", "rotating-token", endpoint.RecordTypeTXT, endpoint.ChallengeTTL).WithACMEChallenge(),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := recordsClient.Get("group", "example.com", "_acme-challenge.foo", dns.TXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := existingTxtValues(&updated)
+	assert.ElementsMatch(t, []string{"existing-token", "rotating-token"}, values, "both challenge values should coexist")
+}
+
+// TestAzureApplyChangesACMEChallengeRotate verifies that rotating a challenge value (one value
+// created, the old one deleted) removes only the retired value and leaves any other
+// concurrently-live values alone.
+func TestAzureApplyChangesACMEChallengeRotate(t *testing.T) {
+	txtRecords := newAzureChallengeRecordSet("old-token", "untouched-token")
+	recordsClient := mockRecordsClient{
+		mockRecordSet: &[]dns.RecordSet{
+			{
+				Name: to.StringPtr("_acme-challenge.foo"),
+				Type: to.StringPtr("Microsoft.Network/dnszones/TXT"),
+				RecordSetProperties: &dns.RecordSetProperties{
+					TTL:        to.Int64Ptr(int64(endpoint.ChallengeTTL)),
+					TxtRecords: &txtRecords,
+				},
+			},
+		},
+	}
+
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("_acme-challenge.foo.example.com", "new-token", endpoint.RecordTypeTXT, endpoint.ChallengeTTL).WithACMEChallenge(),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("_acme-challenge.foo.example.com", "old-token", endpoint.RecordTypeTXT, endpoint.ChallengeTTL).WithACMEChallenge(),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := recordsClient.Get("group", "example.com", "_acme-challenge.foo", dns.TXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := existingTxtValues(&updated)
+	assert.ElementsMatch(t, []string{"untouched-token", "new-token"}, values, "only the rotated value should change")
+}
+
+// mockVerifier records every Verify call it receives, so tests can assert which endpoints
+// ApplyChanges hands off for propagation checking.
+type mockVerifier struct {
+	verified []string
+	err      error
+}
+
+func (v *mockVerifier) Verify(ctx context.Context, dnsName, recordType, target string) error {
+	v.verified = append(v.verified, dnsName)
+	return v.err
+}
+
+// TestAzureApplyChangesVerifiesPropagation checks that ApplyChanges hands every created or
+// updated endpoint - but not deleted ones - to the configured verifier, and that a verification
+// failure doesn't fail ApplyChanges itself.
+func TestAzureApplyChangesVerifiesPropagation(t *testing.T) {
+	recordsClient := mockRecordsClient{}
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+	verifier := &mockVerifier{err: fmt.Errorf("still propagating")}
+	provider.verifier = verifier
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("new.example.com", "1.2.3.4", endpoint.RecordTypeA),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("updated.example.com", "5.6.7.8", endpoint.RecordTypeA),
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("stale.example.com", "9.9.9.9", endpoint.RecordTypeA),
+		},
+		Delete: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("gone.example.com", "", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.ElementsMatch(t, []string{"new.example.com", "updated.example.com"}, verifier.verified, "only created/updated endpoints should be verified")
+}
+
+// TestAzureRecordFollowsPaging checks that Records() keeps calling ListByDNSZoneNextResults
+// until a zone's record sets are exhausted, rather than stopping after the first page.
+func TestAzureRecordFollowsPaging(t *testing.T) {
+	zonesClient := mockZonesClient{
+		mockZoneListResult: &dns.ZoneListResult{
+			Value: &[]dns.Zone{
+				createMockZone("example.com", "/dnszones/example.com"),
+			},
+		},
+	}
+
+	recordsClient := mockRecordsClient{
+		mockRecordSet: &[]dns.RecordSet{
+			createMockRecordSet("@", endpoint.RecordTypeA, "123.123.123.122"),
+		},
+		mockRecordSetPage2: &[]dns.RecordSet{
+			createMockRecordSet("nginx", endpoint.RecordTypeA, "123.123.123.123"),
+		},
+	}
+
+	provider := newAzureProvider(NewDomainFilter([]string{"example.com"}), NewZoneIDFilter([]string{""}), true, "k8s", &zonesClient, &recordsClient)
+
+	actual, err := provider.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validateAzureEndpoints(t, actual, []*endpoint.Endpoint{
+		endpoint.NewEndpoint("example.com", "123.123.123.122", endpoint.RecordTypeA),
+		endpoint.NewEndpoint("nginx.example.com", "123.123.123.123", endpoint.RecordTypeA),
+	})
+}
+
+// TestAzureApplyChangesBatchesRecordSet checks that several endpoints sharing one (zone, name,
+// type) - round-robin A records under one name - reach ARM as a single CreateOrUpdate call
+// carrying every target, instead of one overwriting call per endpoint.
+func TestAzureApplyChangesBatchesRecordSet(t *testing.T) {
+	recordsClient := mockRecordsClient{}
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("round-robin.example.com", "1.2.3.4", endpoint.RecordTypeA),
+			endpoint.NewEndpoint("round-robin.example.com", "5.6.7.8", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, recordsClient.createOrUpdateCalls == 1, "expected a single CreateOrUpdate call for endpoints sharing one record set, got %d", recordsClient.createOrUpdateCalls)
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("round-robin.example.com", "1.2.3.4", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+		endpoint.NewEndpointWithTTL("round-robin.example.com", "5.6.7.8", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+	})
+}
+
+// TestAzureApplyChangesUpdateInPlaceSkipsDelete checks that a record set named in both UpdateOld
+// and UpdateNew - an ordinary in-place update - reaches ARM as a single CreateOrUpdate call, not
+// a Delete followed by a CreateOrUpdate that would briefly leave the name unresolvable.
+func TestAzureApplyChangesUpdateInPlaceSkipsDelete(t *testing.T) {
+	recordsClient := mockRecordsClient{
+		mockRecordSet: &[]dns.RecordSet{
+			createMockRecordSet("www", endpoint.RecordTypeA, "1.2.3.4"),
+		},
+	}
+	provider := newAzureProvider(
+		NewDomainFilter([]string{""}),
+		NewZoneIDFilter([]string{""}),
+		false,
+		"group",
+		&mockZonesClient{
+			mockZoneListResult: &dns.ZoneListResult{
+				Value: &[]dns.Zone{createMockZone("example.com", "/dnszones/example.com")},
+			},
+		},
+		&recordsClient,
+	)
+
+	changes := &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", "1.2.3.4", endpoint.RecordTypeA),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", "5.6.7.8", endpoint.RecordTypeA),
+		},
+	}
+
+	if err := provider.ApplyChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	validateAzureEndpoints(t, recordsClient.deletedEndpoints, []*endpoint.Endpoint{})
+	validateAzureEndpoints(t, recordsClient.updatedEndpoints, []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", "5.6.7.8", endpoint.RecordTypeA, endpoint.TTL(recordTTL)),
+	})
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"strings"
+
+	"github.com/moonwalker/external-dns/endpoint"
+	"github.com/moonwalker/external-dns/plan"
+)
+
+// Provider defines the interface DNS providers implement to be driven by the reconcile loop.
+type Provider interface {
+	Records() ([]*endpoint.Endpoint, error)
+	ApplyChanges(changes *plan.Changes) error
+}
+
+// DomainFilter holds a list of domains that should be considered by a provider, empty list
+// means no filtering.
+type DomainFilter struct {
+	filters []string
+}
+
+// NewDomainFilter returns a new DomainFilter, given a list of domains
+func NewDomainFilter(filters []string) DomainFilter {
+	var trimmed []string
+	for _, filter := range filters {
+		if strings.TrimSpace(filter) != "" {
+			trimmed = append(trimmed, strings.ToLower(strings.TrimSpace(filter)))
+		}
+	}
+	return DomainFilter{filters: trimmed}
+}
+
+// Match checks whether a domain can be found in the DomainFilter.
+func (df DomainFilter) Match(domain string) bool {
+	if len(df.filters) == 0 {
+		return true
+	}
+	strippedDomain := strings.TrimSuffix(strings.ToLower(domain), ".")
+	for _, filter := range df.filters {
+		if strings.HasSuffix(strippedDomain, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// ZoneIDFilter holds a list of zone ids to filter by, empty list means no filtering.
+type ZoneIDFilter struct {
+	filters []string
+}
+
+// NewZoneIDFilter returns a new ZoneIDFilter, given a list of zone ids
+func NewZoneIDFilter(filters []string) ZoneIDFilter {
+	return ZoneIDFilter{filters: filters}
+}
+
+// Match checks whether a zone id can be found in the ZoneIDFilter.
+func (f ZoneIDFilter) Match(zoneID string) bool {
+	if len(f.filters) == 0 {
+		return true
+	}
+	for _, filter := range f.filters {
+		if filter == "" {
+			return true
+		}
+		if strings.Contains(zoneID, filter) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/moonwalker/external-dns/plan"
+)
+
+// Factory builds a Provider from a flat credential/config bag. Providers register a Factory
+// under a name via RegisterProvider, typically from their package's init().
+type Factory func(config map[string]string) (Provider, error)
+
+type registration struct {
+	factory      Factory
+	capabilities plan.Capabilities
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]registration{}
+)
+
+// RegisterProvider registers a provider factory and its declared capabilities under a name.
+// It panics on a duplicate name, since that can only happen from a programming mistake at
+// init() time.
+func RegisterProvider(name string, factory Factory, capabilities plan.Capabilities) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("provider %q already registered", name))
+	}
+	registry[name] = registration{factory: factory, capabilities: capabilities}
+}
+
+// ByName resolves a registered provider factory by name and builds a Provider from it.
+func ByName(name string, config map[string]string) (Provider, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, registered providers are: %v", name, RegisteredProviders())
+	}
+	return reg.factory(config)
+}
+
+// CapabilitiesByName returns the capabilities declared by a registered provider.
+func CapabilitiesByName(name string) (plan.Capabilities, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	reg, ok := registry[name]
+	return reg.capabilities, ok
+}
+
+// RegisteredProviders returns the names of all registered providers, sorted for stable output
+// in --help text and tests.
+func RegisteredProviders() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Minimal RFC 1035 record type/class numbers, just enough to round-trip the record types
+// external-dns itself manages.
+const (
+	qtypeA     uint16 = 1
+	qtypeCNAME uint16 = 5
+	qtypeTXT   uint16 = 16
+	qclassIN   uint16 = 1
+)
+
+func qtypeForRecordType(recordType string) (uint16, error) {
+	switch recordType {
+	case "A":
+		return qtypeA, nil
+	case "CNAME":
+		return qtypeCNAME, nil
+	case "TXT":
+		return qtypeTXT, nil
+	default:
+		return 0, fmt.Errorf("unsupported record type for DoH verification: %s", recordType)
+	}
+}
+
+// buildQuery encodes a minimal single-question DNS wire-format query with recursion desired,
+// suitable for sending as the body of an RFC 8484 DoH request.
+func buildQuery(name string, qtype uint16) ([]byte, error) {
+	question, err := encodeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, 12)
+	msg[2] = 0x01 // RD
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+
+	msg = append(msg, question...)
+
+	qtypeAndClass := make([]byte, 4)
+	binary.BigEndian.PutUint16(qtypeAndClass[0:2], qtype)
+	binary.BigEndian.PutUint16(qtypeAndClass[2:4], qclassIN)
+	msg = append(msg, qtypeAndClass...)
+
+	return msg, nil
+}
+
+func encodeName(name string) ([]byte, error) {
+	var out []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) > 63 {
+			return nil, fmt.Errorf("dns label %q exceeds 63 bytes", label)
+		}
+		out = append(out, byte(len(label)))
+		out = append(out, []byte(label)...)
+	}
+	return append(out, 0), nil
+}
+
+// parseAnswers decodes the answer section of a DNS wire-format message, returning the
+// human-readable values of every answer record matching qtype.
+func parseAnswers(msg []byte, qtype uint16) ([]string, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("dns message shorter than a header")
+	}
+
+	qdcount := int(binary.BigEndian.Uint16(msg[4:6]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	offset := 12
+	for i := 0; i < qdcount; i++ {
+		_, next, err := decodeName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4 // QTYPE + QCLASS
+	}
+
+	var values []string
+	for i := 0; i < ancount; i++ {
+		_, next, err := decodeName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next
+
+		if offset+10 > len(msg) {
+			return nil, fmt.Errorf("dns message truncated in answer record")
+		}
+		rtype := binary.BigEndian.Uint16(msg[offset : offset+2])
+		rdlength := int(binary.BigEndian.Uint16(msg[offset+8 : offset+10]))
+		rdataOffset := offset + 10
+		offset = rdataOffset + rdlength
+
+		if offset > len(msg) {
+			return nil, fmt.Errorf("dns message truncated in rdata")
+		}
+		if rtype != qtype {
+			continue
+		}
+
+		value, err := decodeRData(msg, rtype, rdataOffset, rdlength)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func decodeRData(msg []byte, rtype uint16, rdataOffset, rdlength int) (string, error) {
+	rdata := msg[rdataOffset : rdataOffset+rdlength]
+
+	switch rtype {
+	case qtypeA:
+		if len(rdata) != 4 {
+			return "", fmt.Errorf("invalid A rdata length %d", len(rdata))
+		}
+		return net.IP(rdata).String(), nil
+	case qtypeCNAME:
+		name, _, err := decodeName(msg, rdataOffset)
+		return name, err
+	case qtypeTXT:
+		var parts []string
+		pos := 0
+		for pos < len(rdata) {
+			length := int(rdata[pos])
+			pos++
+			if pos+length > len(rdata) {
+				return "", fmt.Errorf("invalid TXT character-string")
+			}
+			parts = append(parts, string(rdata[pos:pos+length]))
+			pos += length
+		}
+		return strings.Join(parts, ""), nil
+	default:
+		return "", fmt.Errorf("unsupported rdata type %d", rtype)
+	}
+}
+
+// decodeName reads a (possibly compressed, per RFC 1035 section 4.1.4) domain name starting at
+// offset, and returns it along with the offset immediately following it in the original message.
+func decodeName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	pos := offset
+	pointerFollowed := -1
+	jumps := 0
+
+	for {
+		if pos >= len(msg) {
+			return "", 0, fmt.Errorf("dns message truncated in name")
+		}
+		length := int(msg[pos])
+
+		if length == 0 {
+			pos++
+			break
+		}
+
+		if length&0xC0 == 0xC0 {
+			if pos+1 >= len(msg) {
+				return "", 0, fmt.Errorf("dns message truncated in name pointer")
+			}
+			if pointerFollowed == -1 {
+				pointerFollowed = pos + 2
+			}
+			pos = (int(length&0x3F) << 8) | int(msg[pos+1])
+			jumps++
+			if jumps > 128 {
+				return "", 0, fmt.Errorf("dns message name compression loop")
+			}
+			continue
+		}
+
+		pos++
+		if pos+length > len(msg) {
+			return "", 0, fmt.Errorf("dns message truncated in label")
+		}
+		labels = append(labels, string(msg[pos:pos+length]))
+		pos += length
+	}
+
+	if pointerFollowed != -1 {
+		pos = pointerFollowed
+	}
+
+	return strings.Join(labels, "."), pos, nil
+}
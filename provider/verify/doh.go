@@ -0,0 +1,169 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	propagationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dns_propagation_seconds",
+		Help:    "Time taken for a DNS change to become visible on a verification resolver.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"resolver", "record_type"})
+
+	verificationFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dns_verification_failures_total",
+		Help: "Count of DNS verification attempts that never observed the expected value before giving up.",
+	}, []string{"resolver", "record_type"})
+)
+
+func init() {
+	prometheus.MustRegister(propagationSeconds, verificationFailuresTotal)
+}
+
+// DoHVerifier resolves names against one or more DNS-over-HTTPS upstreams (RFC 8484) -
+// Cloudflare, Google, a self-hosted CoreDNS, whatever the operator points it at - and polls
+// them with exponential backoff until the expected value appears or Deadline elapses.
+type DoHVerifier struct {
+	// Upstreams are the DoH endpoint URLs to query, e.g. "https://cloudflare-dns.com/dns-query".
+	Upstreams []string
+	// HTTPClient is used for every DoH request and must be non-nil; NewDoHVerifier sets it to a
+	// client with a 5s timeout.
+	HTTPClient *http.Client
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps how long the backoff is allowed to grow to.
+	MaxBackoff time.Duration
+	// Deadline bounds the total time spent polling before Verify gives up.
+	Deadline time.Duration
+}
+
+// NewDoHVerifier returns a DoHVerifier for the given upstream DoH endpoint URLs, with sane
+// default timings.
+func NewDoHVerifier(upstreams ...string) *DoHVerifier {
+	return &DoHVerifier{
+		Upstreams:      upstreams,
+		HTTPClient:     &http.Client{Timeout: 5 * time.Second},
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Deadline:       5 * time.Minute,
+	}
+}
+
+// Verify polls the configured upstreams until dnsName resolves to target for recordType, or
+// returns an error once Deadline elapses.
+func (v *DoHVerifier) Verify(ctx context.Context, dnsName, recordType, target string) error {
+	qtype, err := qtypeForRecordType(recordType)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	deadline := start.Add(v.Deadline)
+	backoff := v.InitialBackoff
+
+	var lastErr error
+	for {
+		for _, upstream := range v.Upstreams {
+			values, err := v.resolve(ctx, upstream, dnsName, qtype)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if containsValue(values, target) {
+				propagationSeconds.WithLabelValues(upstream, recordType).Observe(time.Since(start).Seconds())
+				return nil
+			}
+			lastErr = fmt.Errorf("%s via %s does not yet resolve to %q (got %v)", dnsName, upstream, target, values)
+		}
+
+		if !time.Now().Before(deadline) {
+			for _, upstream := range v.Upstreams {
+				verificationFailuresTotal.WithLabelValues(upstream, recordType).Inc()
+			}
+			if lastErr == nil {
+				lastErr = fmt.Errorf("no DoH upstreams configured")
+			}
+			return fmt.Errorf("timed out waiting for %s %s to propagate: %w", recordType, dnsName, lastErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > v.MaxBackoff {
+			backoff = v.MaxBackoff
+		}
+	}
+}
+
+// resolve issues a single RFC 8484 POST request (`application/dns-message` body; the base64url
+// GET variant is not used) to upstream and returns the decoded values of every answer record
+// matching qtype.
+func (v *DoHVerifier) resolve(ctx context.Context, upstream, dnsName string, qtype uint16) ([]string, error) {
+	query, err := buildQuery(dnsName, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upstream, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh resolver %s returned status %d", upstream, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAnswers(body, qtype)
+}
+
+func containsValue(values []string, target string) bool {
+	target = strings.TrimSuffix(target, ".")
+	for _, value := range values {
+		if strings.TrimSuffix(value, ".") == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verify confirms that a DNS change a provider reported as applied has actually
+// propagated, by polling one or more resolvers until the expected value shows up or a
+// deadline elapses.
+package verify
+
+import "context"
+
+// Verifier checks whether a DNS record has propagated to a resolver.
+type Verifier interface {
+	// Verify blocks until dnsName resolves to target for the given recordType, or returns an
+	// error once it gives up.
+	Verify(ctx context.Context, dnsName, recordType, target string) error
+}
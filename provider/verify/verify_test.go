@@ -0,0 +1,148 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockDoHServer returns an httptest server that answers every query with a single A or TXT
+// record carrying value, once at least failFirst requests have been answered with NXDOMAIN.
+func newMockDoHServer(t *testing.T, value string, failFirst int32) *httptest.Server {
+	var requests int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := ioutil.ReadAll(r.Body)
+		assert.True(t, err == nil, "failed to read query body")
+
+		qtype, name, err := parseQuestion(query)
+		assert.True(t, err == nil, "failed to parse query")
+
+		n := atomic.AddInt32(&requests, 1)
+
+		var rdata []byte
+		rcode := byte(0)
+		if n <= failFirst {
+			rcode = 3 // NXDOMAIN
+		} else {
+			switch qtype {
+			case qtypeA:
+				rdata = net.ParseIP(value).To4()
+			case qtypeTXT:
+				rdata = append([]byte{byte(len(value))}, []byte(value)...)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(buildResponse(t, name, qtype, rdata, rcode))
+	}))
+}
+
+func TestDoHVerifierVerifySucceedsImmediately(t *testing.T) {
+	server := newMockDoHServer(t, "203.0.113.10", 0)
+	defer server.Close()
+
+	v := NewDoHVerifier(server.URL)
+	v.InitialBackoff = time.Millisecond
+	v.Deadline = time.Second
+
+	err := v.Verify(context.Background(), "app.example.com", "A", "203.0.113.10")
+	assert.True(t, err == nil, "expected verification to succeed")
+}
+
+func TestDoHVerifierVerifyRetriesUntilPropagated(t *testing.T) {
+	server := newMockDoHServer(t, "v=challenge", 2)
+	defer server.Close()
+
+	v := NewDoHVerifier(server.URL)
+	v.InitialBackoff = time.Millisecond
+	v.MaxBackoff = 5 * time.Millisecond
+	v.Deadline = time.Second
+
+	err := v.Verify(context.Background(), "_acme-challenge.example.com", "TXT", "v=challenge")
+	assert.True(t, err == nil, "expected verification to eventually succeed")
+}
+
+func TestDoHVerifierVerifyTimesOutOnMismatch(t *testing.T) {
+	server := newMockDoHServer(t, "203.0.113.99", 0)
+	defer server.Close()
+
+	v := NewDoHVerifier(server.URL)
+	v.InitialBackoff = time.Millisecond
+	v.MaxBackoff = 2 * time.Millisecond
+	v.Deadline = 20 * time.Millisecond
+
+	err := v.Verify(context.Background(), "app.example.com", "A", "203.0.113.10")
+	assert.True(t, err != nil, "expected verification to time out on a mismatched value")
+}
+
+// --- tiny test-only helpers for driving the mock DoH server; production code never needs to
+// parse a question or synthesize a response, only build one and parse an answer. ---
+
+func parseQuestion(msg []byte) (uint16, string, error) {
+	name, offset, err := decodeName(msg, 12)
+	if err != nil {
+		return 0, "", err
+	}
+	qtype := binary.BigEndian.Uint16(msg[offset : offset+2])
+	return qtype, name, nil
+}
+
+func buildResponse(t *testing.T, name string, qtype uint16, rdata []byte, rcode byte) []byte {
+	question, err := encodeName(name)
+	assert.True(t, err == nil, "failed to encode question name")
+
+	msg := make([]byte, 12)
+	msg[2] = 0x81 // QR + RD
+	msg[3] = 0x80 | rcode
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	if rcode == 0 {
+		binary.BigEndian.PutUint16(msg[6:8], 1)
+	}
+
+	msg = append(msg, question...)
+	qtypeAndClass := make([]byte, 4)
+	binary.BigEndian.PutUint16(qtypeAndClass[0:2], qtype)
+	binary.BigEndian.PutUint16(qtypeAndClass[2:4], qclassIN)
+	msg = append(msg, qtypeAndClass...)
+
+	if rcode != 0 {
+		return msg
+	}
+
+	answerName, err := encodeName(name)
+	assert.True(t, err == nil, "failed to encode answer name")
+	msg = append(msg, answerName...)
+	msg = append(msg, qtypeAndClass...)
+	ttlAndLength := make([]byte, 6)
+	binary.BigEndian.PutUint32(ttlAndLength[0:4], 60)
+	binary.BigEndian.PutUint16(ttlAndLength[4:6], uint16(len(rdata)))
+	msg = append(msg, ttlAndLength...)
+	msg = append(msg, rdata...)
+
+	return msg
+}